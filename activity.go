@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// direction is "in" or "out" of the tracked address, "self" when it sent the
+// tx to itself.
+func (a activity) direction() string {
+	to := a.tx.to != nil && *a.tx.to == a.address
+	switch {
+	case a.tx.from == a.address && to:
+		return "self"
+	case a.tx.from == a.address:
+		return "out"
+	}
+	return "in"
+}
+
+// counterparty is the other side of the tx from the tracked address.
+func (a activity) counterparty() string {
+	switch a.direction() {
+	case "in":
+		return shortAddress(a.tx.from)
+	case "self":
+		return "itself"
+	}
+	if a.tx.to == nil {
+		return "new contract"
+	}
+	return shortAddress(*a.tx.to)
+}
+
+// age is how long ago a block's timestamp was, in its largest unit.
+func age(timestamp int64) string {
+	elapsed := max(time.Since(time.Unix(timestamp, 0)), 0)
+	switch {
+	case elapsed < time.Minute:
+		return fmt.Sprintf("%ds ago", int(elapsed.Seconds()))
+	case elapsed < time.Hour:
+		return fmt.Sprintf("%dm ago", int(elapsed.Minutes()))
+	}
+	return fmt.Sprintf("%dh ago", int(elapsed.Hours()))
+}
+
+// scrollable is every line of the tracking view when it's one that scrolls.
+func (m *model) scrollable() []string {
+	switch m.screenContent.trackingView {
+	case 3:
+		return m.renderActivity()
+	case 5:
+		return m.renderPortfolio()
+	}
+	return nil
+}
+
+// scrollTracking moves the activity feed or portfolio step lines down,
+// negative steps go back towards the top.
+func (m *model) scrollTracking(step int) {
+	rows := m.styles.tokenTracking.GetHeight() - 2
+	last := max(len(m.scrollable())-rows, 0)
+	m.screenContent.scroll = min(max(m.screenContent.scroll+step, 0), last)
+}
+
+// renderActivity is the tracked addresses' activity, newest first.
+func (m *model) renderActivity() []string {
+	feed := m.trackingEOA[m.chain.Id].activity
+	if len(feed) == 0 {
+		return []string{fmt.Sprintf("nothing from tracked addresses in the last %d blocks", m.memory[m.chain.Id].window)}
+	}
+
+	colours := map[string]lipgloss.Style{
+		"in":   m.renderer.NewStyle().Foreground(lipgloss.Color("#5fd75f")),
+		"out":  m.renderer.NewStyle().Foreground(lipgloss.Color("#ff5f5f")),
+		"self": m.renderer.NewStyle().Foreground(lipgloss.Color("#808080")),
+	}
+
+	var lines []string
+	for i := len(feed) - 1; i >= 0; i-- {
+		a := feed[i]
+		value := a.tx.value
+		if value == nil {
+			value = new(big.Int)
+		}
+		status := ""
+		if a.tx.failed() {
+			status = " ✗"
+		}
+		line := fmt.Sprintf("%-4s %-12s %-12s %12s %-5s #%-9d %s%s",
+			a.direction(),
+			truncate(a.name, 12),
+			a.counterparty(),
+			ToDecimal(value, 18).Truncate(4),
+			m.chain.NativeCurrency,
+			a.tx.blockNumber,
+			age(a.timestamp),
+			status,
+		)
+		lines = append(lines, colours[a.direction()].Render(line))
+	}
+	return lines
+}
@@ -1,29 +1,49 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish/bubbletea"
-	"github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/google/uuid"
 )
 
+// chainInfo is shared by every session. The listener fields are only touched
+// by the chain's listener goroutine, clients go through the hub.
 type chainInfo struct {
-	connectedClients map[uuid.UUID]*client
-	sub              ethereum.Subscription
-	ethClient        *ethclient.Client
+	chain     chain
+	hub       *hub
+	lastBlock int                 // number of the last block sent to clients
+	hashes    map[int]common.Hash // recent delivered block hashes by number
+	endpoints []*endpoint
+	recorder  *recorder // nil unless the chain is configured to record
+	rpc       *rpcBatcher
+	decimals  *tokenDecimals
+	// set once the endpoint turned out not to have eth_getBlockReceipts
+	noBlockReceipts bool
+	// when a simulated chain's first block was made, kept here so a
+	// restarted listener sees the same chain
+	genesis time.Time
+	// a replayed chain's recording and how far it's got, reused by restarted
+	// listeners so the replay carries on instead of starting over
+	replay *replaySource
 }
 
 type app struct {
-	chains []chain
-	// profiles      []profile
+	chains        []chain
+	profiles      []profile
 	chainIdToInfo map[string]*chainInfo
+	tokenTracking TokenTracking
+	abis          *abiRegistry
+	settings      *settingsStore
 }
 
 func (a *app) ProgramHandler(s ssh.Session) *tea.Program {
@@ -31,9 +51,17 @@ func (a *app) ProgramHandler(s ssh.Session) *tea.Program {
 	renderer := bubbletea.MakeRenderer(s)
 	model := initialModel(a.chains)
 	model.app = a
+	for _, chain := range a.chains {
+		model.trackingERC721[chain.Id] = a.tokenTracking.ERC721.tracking()
+	}
+	model.profiles = append([]profile(nil), a.profiles...)
+	model.user = fingerprint(s)
+	if settings, ok := a.settings.load(model.user); ok {
+		log.Printf("loaded settings for %s", model.user)
+		model.applySettings(settings)
+	}
 	model.renderer = renderer
 	// model.client = &client{id: uuid.New()}
-	// // model.trackingProfile = a.profiles[0] // temporary
 	// model.memory.window = 10
 	// model.memory.blocks.SetBaseCap(model.memory.window)
 	// model.currentPage = SelectChain
@@ -41,9 +69,39 @@ func (a *app) ProgramHandler(s ssh.Session) *tea.Program {
 	p := tea.NewProgram(model, tea.WithInput(s), tea.WithOutput(s), tea.WithAltScreen(), tea.WithMouseAllMotion())
 	model.client.program = p
 
+	// sessions can drop without quitting, make sure they stop receiving blocks
+	go func() {
+		<-s.Context().Done()
+		for _, chain := range a.chains {
+			a.disconnectClient(chain, model.client.id)
+		}
+	}()
+
 	return p
 }
 
+// polls reports whether heads on url should be polled rather than subscribed
+// to, plain http endpoints can't push notifications.
+func (c chain) polls(url string) bool {
+	return c.Mode == "poll" || strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+func (c chain) pollInterval() time.Duration {
+	interval, err := time.ParseDuration(c.PollInterval)
+	if err != nil || interval <= 0 {
+		return defaultPollInterval
+	}
+	return interval
+}
+
+func (c chain) blockTime() time.Duration {
+	blockTime, err := time.ParseDuration(c.BlockTime)
+	if err != nil || blockTime <= 0 {
+		return defaultBlockTime
+	}
+	return blockTime
+}
+
 func (a *app) configureChains() {
 	chainsFile, err := os.Open("config/chains.json")
 	if err != nil {
@@ -55,45 +113,91 @@ func (a *app) configureChains() {
 
 	json.NewDecoder(chainsFile).Decode(&c)
 
-	a.chains = c.Chains
+	a.chains = append(c.Chains, replayChains(c.Chains)...)
+
+	for i, chain := range a.chains {
+		// older configs only have the single wss url
+		if len(chain.Endpoints) == 0 && chain.Wss != "" {
+			chain.Endpoints = []string{chain.Wss}
+			a.chains[i] = chain
+		}
+		// simulated and replay chains don't dial anything but still get an
+		// endpoint to keep score with
+		if len(chain.Endpoints) == 0 && chain.Source != "" {
+			chain.Endpoints = []string{chain.Source}
+			a.chains[i] = chain
+		}
 
-	for _, chain := range a.chains {
 		a.chainIdToInfo[chain.Id] = new(chainInfo)
-		a.chainIdToInfo[chain.Id].connectedClients = make(map[uuid.UUID]*client)
+		a.chainIdToInfo[chain.Id].chain = chain
+		a.chainIdToInfo[chain.Id].hub = newHub(func(ctx context.Context) {
+			log.Printf("starting %s eth client", chain.Name)
+			a.ethClient(ctx, chain)
+			log.Printf("closed %s eth client", chain.Name)
+		})
+		a.chainIdToInfo[chain.Id].endpoints = newEndpoints(chain.Endpoints, chain.RateLimit)
+		a.chainIdToInfo[chain.Id].rpc = newRPCBatcher()
+		a.chainIdToInfo[chain.Id].decimals = newTokenDecimals()
+		if chain.Source == "simulated" {
+			a.chainIdToInfo[chain.Id].genesis = time.Now()
+		}
 
 		// a.chainIdToProgramsTest[chain.Id] = make(map[uuid.UUID]*client)
 	}
 
 }
 
-// func (a *app) configureProfiles() {
-// 	profileFile, err := os.Open("config/profiles.json")
-// 	if err != nil {
-// 		fmt.Println(err)
-// 	}
-// 	defer profileFile.Close()
+func (a *app) configureProfiles() {
+	profileFile, err := os.Open("config/profiles.json")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer profileFile.Close()
 
-// 	var p Profiles
+	var p Profiles
 
-// 	json.NewDecoder(profileFile).Decode(&p)
+	if err := json.NewDecoder(profileFile).Decode(&p); err != nil {
+		fmt.Println("error reading profiles config", err)
+	}
 
-// 	a.profiles = p.Profiles
+	a.profiles = p.Profiles
 
-// }
+}
 
-// func (a *app) connectClient(chainId string, clientId string) {
+func (a *app) configureTokenTracking() {
+	trackingFile, err := os.Open("config/tokenTracking.json")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer trackingFile.Close()
 
-// }
+	if err := json.NewDecoder(trackingFile).Decode(&a.tokenTracking); err != nil {
+		fmt.Println("error reading token tracking config", err)
+	}
+}
 
-func (a *app) disconnectClient(chain chain, clientId uuid.UUID, sub ethereum.Subscription) {
-	log.Printf("removing client %s from %s connection", clientId.String(), chain.Name)
-	delete(a.chainIdToInfo[chain.Id].connectedClients, clientId)
-	if len(a.chainIdToInfo[chain.Id].connectedClients) == 0 && sub != nil {
-		log.Printf("closing %s eth client\n", chain.Name)
-		sub.Unsubscribe()
+// connectClient subscribes c to the chain and sends it up to window recent
+// blocks to fill its memory with.
+func (a *app) connectClient(chain chain, c *client, window int) {
+	log.Printf("adding client %s to %s connection", c.id.String(), chain.Name)
+	a.chainIdToInfo[chain.Id].hub.subscribe(c, window)
+}
 
+func (a *app) disconnectClient(chain chain, clientId uuid.UUID) {
+	info, ok := a.chainIdToInfo[chain.Id]
+	if !ok {
+		return
+	}
+	if info.hub.unsubscribe(clientId) {
+		log.Printf("removed last client %s from %s connection, closing eth client", clientId.String(), chain.Name)
 	}
+}
 
+// broadcast sends msg to every client connected to the chain.
+func (a *app) broadcast(chainId string, msg tea.Msg) {
+	a.chainIdToInfo[chainId].hub.broadcast(msg)
 }
 
 // func (a *app) checkChainStatus() {
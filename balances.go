@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// how many balances are kept per tracked address
+const maxBalanceHistory = 10
+
+type balance struct {
+	blockNumber int
+	value       *big.Int
+}
+
+// balanceHistory is a tracked address's native balance over the session.
+type balanceHistory struct {
+	start   balance   // first balance fetched this session
+	history []balance // newest last
+}
+
+func (h *balanceHistory) current() balance {
+	if len(h.history) == 0 {
+		return h.start
+	}
+	return h.history[len(h.history)-1]
+}
+
+// add records value at blockNumber, responses for blocks older than the last
+// one recorded are dropped.
+func (h *balanceHistory) add(blockNumber int, value *big.Int) {
+	if h.start.value == nil {
+		h.start = balance{blockNumber, value}
+	}
+	if len(h.history) > 0 && blockNumber <= h.current().blockNumber {
+		return
+	}
+	h.history = append(h.history, balance{blockNumber, value})
+	if len(h.history) > maxBalanceHistory {
+		h.history = h.history[len(h.history)-maxBalanceHistory:]
+	}
+}
+
+// sent to a session with the native balances of tracked addresses at a block
+type BalancesMsg struct {
+	chainId     string
+	blockNumber int
+	addresses   []common.Address // what was asked for
+	balances    map[common.Address]*big.Int
+	err         error
+}
+
+// fetchBalances gets the balance at block of every tracked address that sent
+// or received a tx in it, and of those that don't have one yet and aren't
+// already being read.
+func (m *model) fetchBalances(block BlockMsg) tea.Cmd {
+	// recordings don't have balances
+	if m.chain.Source == "replay" {
+		return nil
+	}
+	tracked := m.trackingEOA[m.chain.Id]
+	chainId := m.chain.Id
+
+	var addresses []common.Address
+	for _, address := range tracked.addresses {
+		first := tracked.balances[address] == nil
+		seen := first && !tracked.fetching[address]
+		for _, tx := range block.transactions {
+			seen = seen || tx.from == address || (tx.to != nil && *tx.to == address)
+		}
+		if !seen {
+			continue
+		}
+		addresses = append(addresses, address)
+		if first {
+			if tracked.fetching == nil {
+				tracked.fetching = make(map[common.Address]bool)
+			}
+			tracked.fetching[address] = true
+		}
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+	m.trackingEOA[chainId] = tracked
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		// one call per address through the batcher so sessions tracking the
+		// same address at the same block share the request
+		blockNumber := hexutil.EncodeUint64(uint64(block.blockNumber))
+		results := make([]hexutil.Big, len(addresses))
+		errs := make([]error, len(addresses))
+		var wg sync.WaitGroup
+		for i, address := range addresses {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = m.app.chainIdToInfo[chainId].rpc.Call(ctx, &results[i], "eth_getBalance", address, blockNumber)
+			}()
+		}
+		wg.Wait()
+
+		balances := make(map[common.Address]*big.Int, len(addresses))
+		for i, address := range addresses {
+			if errs[i] != nil {
+				return BalancesMsg{chainId: chainId, blockNumber: block.blockNumber, addresses: addresses, err: errs[i]}
+			}
+			balances[address] = results[i].ToInt()
+		}
+		return BalancesMsg{chainId: chainId, blockNumber: block.blockNumber, addresses: addresses, balances: balances}
+	}
+}
+
+func (m *model) saveBalances(msg BalancesMsg) {
+	eoaTracking := m.trackingEOA[msg.chainId]
+	if eoaTracking.balances == nil {
+		eoaTracking.balances = make(map[common.Address]*balanceHistory)
+	}
+	for address, value := range msg.balances {
+		if eoaTracking.balances[address] == nil {
+			eoaTracking.balances[address] = new(balanceHistory)
+		}
+		eoaTracking.balances[address].add(msg.blockNumber, value)
+	}
+	m.trackingEOA[msg.chainId] = eoaTracking
+}
+
+// rollbackBalances forgets balances fetched at blocks that were reorged out,
+// the addresses get fetched again when they show up in the new blocks. A
+// reorged start moves to the oldest balance left, or is read again with the
+// next block when there's none.
+func (m *model) rollbackBalances(from int) {
+	balances := m.trackingEOA[m.chain.Id].balances
+	for address, history := range balances {
+		history.history = filter(history.history, func(b balance) bool {
+			return b.blockNumber < from
+		})
+		if history.start.blockNumber < from {
+			continue
+		}
+		if len(history.history) == 0 {
+			delete(balances, address)
+			continue
+		}
+		history.start = history.history[0]
+	}
+}
+
+// renderBalances is a line per tracked address with its balance, the change
+// since the session started and a sparkline of its recent balances.
+func (m *model) renderBalances(rows int) []string {
+	tracked := m.trackingEOA[m.chain.Id]
+	if len(tracked.addresses) == 0 {
+		return []string{"no EOA addresses tracked, add some in set up or from a profile"}
+	}
+
+	up := m.renderer.NewStyle().Foreground(lipgloss.Color("#5fd75f"))
+	down := m.renderer.NewStyle().Foreground(lipgloss.Color("#ff5f5f"))
+
+	var lines []string
+	for i, address := range tracked.addresses {
+		if len(lines) >= rows {
+			break
+		}
+		line := fmt.Sprintf("%-12s %-12s", truncate(tracked.names[i], 12), shortAddress(address))
+		history := tracked.balances[address]
+		if history == nil {
+			lines = append(lines, line+" fetching balance...")
+			continue
+		}
+
+		current := history.current()
+		delta := new(big.Int).Sub(current.value, history.start.value)
+		change := fmt.Sprintf("%+.4f", ToDecimal(delta, 18).InexactFloat64())
+		switch delta.Sign() {
+		case 1:
+			change = up.Render(change)
+		case -1:
+			change = down.Render(change)
+		}
+
+		values := make([]float64, len(history.history))
+		for j, b := range history.history {
+			values[j] = ToDecimal(b.value, 18).InexactFloat64()
+		}
+
+		line = fmt.Sprintf("%s %12s %-5s %s since #%d %s",
+			line,
+			ToDecimal(current.value, 18).Truncate(4),
+			m.chain.NativeCurrency,
+			change,
+			history.start.blockNumber,
+			sparkline(values),
+		)
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// how long a call waits for others to join its batch
+	batchWindow = 5 * time.Millisecond
+	// a batch is sent straight away once it has this many calls
+	maxBatchSize = 50
+	batchTimeout = 30 * time.Second
+)
+
+var errNotConnected = errors.New("chain is not connected")
+
+// rpcBatcher is what every call on a chain goes through, whichever session or
+// listener makes it. Calls made within batchWindow of each other go out as one
+// JSON-RPC batch, and identical calls share a single request while it is in
+// flight. It stands in for whatever source the listener is connected to at the
+// moment.
+type rpcBatcher struct {
+	mu       sync.Mutex
+	source   BlockSource // nil while the listener is (re)connecting
+	pending  []*batchedCall
+	inflight map[string]*batchedCall
+}
+
+type batchedCall struct {
+	key    string
+	method string
+	args   []interface{}
+	result json.RawMessage
+	err    error
+	done   chan struct{}
+}
+
+func newRPCBatcher() *rpcBatcher {
+	return &rpcBatcher{inflight: make(map[string]*batchedCall)}
+}
+
+// attach routes calls to source until detach is called.
+func (b *rpcBatcher) attach(source BlockSource) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.source = source
+}
+
+func (b *rpcBatcher) detach() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.source = nil
+}
+
+func (b *rpcBatcher) current() (BlockSource, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.source == nil {
+		return nil, errNotConnected
+	}
+	return b.source, nil
+}
+
+func (b *rpcBatcher) Call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	params, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	key := method + string(params)
+
+	b.mu.Lock()
+	if b.source == nil {
+		b.mu.Unlock()
+		return errNotConnected
+	}
+	call, ok := b.inflight[key]
+	if !ok {
+		call = &batchedCall{key: key, method: method, args: args, done: make(chan struct{})}
+		b.inflight[key] = call
+		b.pending = append(b.pending, call)
+		switch len(b.pending) {
+		case 1:
+			time.AfterFunc(batchWindow, b.flush)
+		case maxBatchSize:
+			go b.flush()
+		}
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if call.err != nil {
+		return call.err
+	}
+	return json.Unmarshal(call.result, result)
+}
+
+// flush sends everything pending as one batch.
+func (b *rpcBatcher) flush() {
+	b.mu.Lock()
+	calls, source := b.pending, b.source
+	b.pending = nil
+	b.mu.Unlock()
+	if len(calls) == 0 {
+		return
+	}
+
+	elems := make([]rpc.BatchElem, len(calls))
+	for i, call := range calls {
+		elems[i] = rpc.BatchElem{Method: call.method, Args: call.args, Result: &call.result}
+	}
+
+	err := errNotConnected
+	if source != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), batchTimeout)
+		err = source.BatchCall(ctx, elems)
+		cancel()
+	}
+
+	b.mu.Lock()
+	for i, call := range calls {
+		call.err = err
+		if err == nil {
+			call.err = elems[i].Error
+		}
+		delete(b.inflight, call.key)
+		close(call.done)
+	}
+	b.mu.Unlock()
+}
+
+// BatchCall sends calls that are already grouped straight through, they still
+// count against the endpoint's rate limit.
+func (b *rpcBatcher) BatchCall(ctx context.Context, calls []rpc.BatchElem) error {
+	source, err := b.current()
+	if err != nil {
+		return err
+	}
+	return source.BatchCall(ctx, calls)
+}
+
+func (b *rpcBatcher) Heads(ctx context.Context, headers chan<- *types.Header) (ethereum.Subscription, error) {
+	source, err := b.current()
+	if err != nil {
+		return nil, err
+	}
+	return source.Heads(ctx, headers)
+}
+
+func (b *rpcBatcher) Status() EndpointMsg {
+	source, err := b.current()
+	if err != nil {
+		return EndpointMsg{}
+	}
+	return source.Status()
+}
+
+// Close does nothing, the listener closes the sources it opens.
+func (b *rpcBatcher) Close() {}
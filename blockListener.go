@@ -8,25 +8,94 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/google/uuid"
-	"github.com/valyala/fastjson"
 )
 
-var jsonParser fastjson.Parser
-
 type Transaction struct {
-	to, from, gas, hash, value string
-	blockNumber                int
+	hash                 common.Hash
+	from                 common.Address
+	to                   *common.Address // nil for contract creations
+	value                *big.Int
+	gas, nonce           uint64
+	txType               uint8
+	input                []byte
+	gasPrice             *big.Int
+	maxFeePerGas         *big.Int // nil before EIP-1559 transactions
+	maxPriorityFeePerGas *big.Int
+	priorityFee          *big.Int // tip per gas over the block's base fee, nil without one
+	index, blockNumber   int
+	receipt              *Receipt // nil unless the chain fetches receipts
+}
+
+// rpcTransaction and rpcBlock are eth_getBlockByNumber responses as the node
+// sends them.
+type rpcTransaction struct {
+	Hash                 common.Hash     `json:"hash"`
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to"`
+	Value                *hexutil.Big    `json:"value"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Type                 hexutil.Uint64  `json:"type"`
+	Input                hexutil.Bytes   `json:"input"`
+	GasPrice             *hexutil.Big    `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	TransactionIndex     hexutil.Uint64  `json:"transactionIndex"`
+}
+
+type rpcBlock struct {
+	Hash          common.Hash      `json:"hash"`
+	ParentHash    common.Hash      `json:"parentHash"`
+	Timestamp     hexutil.Uint64   `json:"timestamp"`
+	Miner         common.Address   `json:"miner"`
+	GasUsed       hexutil.Uint64   `json:"gasUsed"`
+	GasLimit      hexutil.Uint64   `json:"gasLimit"`
+	BaseFeePerGas *hexutil.Big     `json:"baseFeePerGas"`
+	Size          hexutil.Uint64   `json:"size"`
+	Transactions  []rpcTransaction `json:"transactions"`
+}
+
+func (tx rpcTransaction) decode(blockNumber int) Transaction {
+	return Transaction{
+		hash:                 tx.Hash,
+		from:                 tx.From,
+		to:                   tx.To,
+		value:                bigOrZero(tx.Value),
+		gas:                  uint64(tx.Gas),
+		nonce:                uint64(tx.Nonce),
+		txType:               uint8(tx.Type),
+		input:                tx.Input,
+		gasPrice:             bigOrZero(tx.GasPrice),
+		maxFeePerGas:         (*big.Int)(tx.MaxFeePerGas),
+		maxPriorityFeePerGas: (*big.Int)(tx.MaxPriorityFeePerGas),
+		index:                int(tx.TransactionIndex),
+		blockNumber:          blockNumber,
+	}
+}
+
+func bigOrZero(value *hexutil.Big) *big.Int {
+	if value == nil {
+		return new(big.Int)
+	}
+	return value.ToInt()
 }
 
 type BlockMsg struct {
-	blockNumber  int
-	timestamp    *big.Int
-	transactions []Transaction
-	totalValue   *big.Int
+	blockNumber       int
+	hash              common.Hash
+	parentHash        common.Hash
+	timestamp         *big.Int
+	miner             common.Address // fee recipient
+	gasUsed, gasLimit uint64
+	baseFee           *big.Int // nil before London
+	size              uint64
+	transactions      []Transaction
+	totalValue        *big.Int
+	backfilled        bool // fetched to fill a gap rather than announced as a new head
+	fromBacklog       bool // an older block from the hub's cache sent to a late joiner
 }
 
 // error codes
@@ -40,216 +109,307 @@ type ErrMsg struct {
 	// code  int
 }
 
-// var tokenTracking []common.Address
-
-func (a *app) getBlock(c *ethclient.Client, blockNumber *big.Int, chainId string) {
-	var raw json.RawMessage
-	callErr := c.Client().Call(&raw, "eth_getBlockByNumber", hexutil.EncodeBig(blockNumber), true)
-	block, blockDecodeErr := jsonParser.Parse(string(raw))
+// sent while the listener is waiting to redial a dropped connection
+type ReconnectMsg struct {
+	attempt int
+	delay   time.Duration
+	err     string
+}
 
-	transactions := block.GetArray("transactions")
-	timestampHex := string(block.GetStringBytes("timestamp"))
-	timestamp, err := hexutil.DecodeBig(timestampHex)
-	if err != nil {
-		fmt.Println("error decoding timestamp", err)
-		timestamp = big.NewInt(time.Now().Unix())
-	}
+// sent when blocks from..to were dropped from the canonical chain
+type ReorgMsg struct {
+	from, to, depth int
+}
 
-	if string(raw) == "null" || callErr != nil || blockDecodeErr != nil {
-		log.Println("error while fetching block")
-		errorMessage := ErrMsg{
-			msg:   fmt.Sprintf("failed to fetch block %s", blockNumber.String()),
-			isErr: false,
-		}
-		blockMsg := BlockMsg{
-			blockNumber:  int(blockNumber.Int64()),
-			transactions: make([]Transaction, 0),
-			totalValue:   big.NewInt(0),
-			timestamp:    timestamp,
-		}
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+	// how long a subscription has to stay up before its drop starts the
+	// backoff over
+	minHealthyConnection = 30 * time.Second
+	// most blocks fetched to fill a single gap, older ones are skipped
+	maxBackfill = 50
+	// consecutive failed eth_blockNumber calls before a polled endpoint is dropped
+	maxPollFailures = 3
+	// how many recent block hashes are kept to find a reorg's common ancestor
+	maxReorgDepth = 64
+)
 
-		for _, client := range a.chainIdToInfo[chainId].connectedClients {
-			client.program.Send(errorMessage)
-			client.program.Send(blockMsg)
+// getBlock fetches and decodes a block, raw is the node's response.
+func (a *app) getBlock(ctx context.Context, source BlockSource, blockNumber *big.Int, backfilled bool) (BlockMsg, json.RawMessage, error) {
+	var raw json.RawMessage
+	callErr := source.Call(ctx, &raw, "eth_getBlockByNumber", hexutil.EncodeBig(blockNumber), true)
 
-		}
-		return
+	var block rpcBlock
+	if callErr != nil || string(raw) == "null" || len(raw) == 0 {
+		return BlockMsg{}, nil, fmt.Errorf("failed to fetch block %s", blockNumber.String())
+	}
+	if err := json.Unmarshal(raw, &block); err != nil {
+		log.Println("error decoding block", err)
+		return BlockMsg{}, nil, fmt.Errorf("failed to decode block %s", blockNumber.String())
 	}
 
 	blockMsg := BlockMsg{
 		blockNumber:  int(blockNumber.Int64()),
-		transactions: make([]Transaction, len(transactions)),
+		hash:         block.Hash,
+		parentHash:   block.ParentHash,
+		transactions: make([]Transaction, len(block.Transactions)),
 		totalValue:   big.NewInt(0),
-		timestamp:    timestamp,
+		timestamp:    new(big.Int).SetUint64(uint64(block.Timestamp)),
+		miner:        block.Miner,
+		gasUsed:      uint64(block.GasUsed),
+		gasLimit:     uint64(block.GasLimit),
+		baseFee:      (*big.Int)(block.BaseFeePerGas),
+		size:         uint64(block.Size),
+		backfilled:   backfilled,
 	}
 
-	for i, transaction := range transactions {
-		value := string(transaction.GetStringBytes("value"))
-		valueBigInt := new(big.Int)
-		valueBigInt.SetString(value, 0)
+	for i, transaction := range block.Transactions {
+		blockMsg.transactions[i] = transaction.decode(blockMsg.blockNumber)
+		blockMsg.transactions[i].priorityFee = effectiveTip(blockMsg.transactions[i], blockMsg.baseFee)
+		blockMsg.totalValue.Add(blockMsg.totalValue, blockMsg.transactions[i].value)
+	}
 
-		blockMsg.transactions[i] = Transaction{
-			to:          string(transaction.GetStringBytes("to")),
-			from:        string(transaction.GetStringBytes("from")),
-			gas:         string(transaction.GetStringBytes("gas")),
-			hash:        string(transaction.GetStringBytes("hash")),
-			value:       valueBigInt.String(),
-			blockNumber: blockMsg.blockNumber,
-		}
+	return blockMsg, raw, nil
 
-		blockMsg.totalValue.Add(blockMsg.totalValue, valueBigInt)
+}
 
+// getParentHash returns the parent hash of the canonical block at blockNumber
+// without fetching its transactions.
+func getParentHash(ctx context.Context, source BlockSource, blockNumber int) (common.Hash, error) {
+	var raw json.RawMessage
+	err := source.Call(ctx, &raw, "eth_getBlockByNumber", hexutil.EncodeBig(big.NewInt(int64(blockNumber))), false)
+	if err != nil {
+		return common.Hash{}, err
 	}
-
-	for _, client := range a.chainIdToInfo[chainId].connectedClients {
-		// if client.program != nil {
-		client.program.Send(blockMsg)
-		// }
+	var block struct {
+		ParentHash *common.Hash `json:"parentHash"`
+	}
+	if err := json.Unmarshal(raw, &block); err != nil || block.ParentHash == nil {
+		return common.Hash{}, fmt.Errorf("failed to fetch block %d", blockNumber)
 	}
+	return *block.ParentHash, nil
+}
 
-	// return block
+// sendBlock remembers the block's hash for reorg detection and forwards it to
+// every connected client.
+func (a *app) sendBlock(chainId string, block BlockMsg, raw json.RawMessage) {
+	info := a.chainIdToInfo[chainId]
+	if info.recorder != nil {
+		info.recorder.record(block, raw)
+	}
+	info.hashes[block.blockNumber] = block.hash
+	delete(info.hashes, block.blockNumber-maxReorgDepth)
+	info.lastBlock = block.blockNumber
 
+	a.broadcast(chainId, block)
 }
 
-func ethClient(a *app, chainIndex int) {
-	chain := a.chains[chainIndex]
-	wssclient, err := ethclient.Dial(chain.Wss)
+// deliverHead forwards the block at blockNumber. If the block does not build on
+// the last one delivered the orphaned blocks are reported with a ReorgMsg, and
+// any blocks skipped since the last one delivered are fetched in order first.
+func (a *app) deliverHead(ctx context.Context, source BlockSource, blockNumber *big.Int, chainId string) {
+	info := a.chainIdToInfo[chainId]
+	head := int(blockNumber.Int64())
 
+	block, raw, err := a.getBlock(ctx, source, blockNumber, false)
 	if err != nil {
-		message := ErrMsg{
-			msg:   "error creating eth client",
-			isErr: true,
+		log.Println("error while fetching block", err)
+		a.broadcast(chainId, ErrMsg{msg: err.Error(), isErr: false})
+		return
+	}
+
+	if info.lastBlock != 0 {
+		if hash, ok := info.hashes[head]; ok && hash == block.hash {
+			log.Printf("skipping block %d on %s, already delivered", head, chainId)
+			return
 		}
-		for _, client := range a.chainIdToInfo[chain.Id].connectedClients {
-			if client.program != nil {
-				client.program.Send(message)
-			}
 
+		parent, ok := info.hashes[head-1]
+		if head <= info.lastBlock || (ok && parent != block.parentHash) {
+			a.rollback(ctx, source, block, chainId)
 		}
-		return
 	}
-	a.chainIdToInfo[chain.Id].ethClient = wssclient
 
-	startingBlock, _ := wssclient.BlockNumber(context.Background())
+	if info.lastBlock != 0 && head > info.lastBlock+1 {
+		from := max(info.lastBlock+1, head-maxBackfill)
+		log.Printf("backfilling blocks %d to %d on %s", from, head-1, chainId)
+		for number := from; number < head; number++ {
+			backfill, backfillRaw, err := a.getBlock(ctx, source, big.NewInt(int64(number)), true)
+			if err != nil {
+				log.Println("error while backfilling block", err)
+				a.broadcast(chainId, ErrMsg{msg: err.Error(), isErr: false})
+				continue
+			}
+			a.attachReceipts(ctx, source, chainId, &backfill)
+			a.sendBlock(chainId, backfill, backfillRaw)
+		}
+	}
 
-	a.getBlock(wssclient, big.NewInt(int64(startingBlock)), chain.Id)
+	a.attachReceipts(ctx, source, chainId, &block)
+	a.sendBlock(chainId, block, raw)
+	a.broadcast(chainId, source.Status())
+}
 
-	// fmt.Println("starting block", block)
+// rollback walks back from head until it reaches a block we delivered that is
+// still canonical, forgets everything after it and tells clients which blocks
+// were orphaned. The next delivered block is then backfilled from there.
+func (a *app) rollback(ctx context.Context, source BlockSource, head BlockMsg, chainId string) {
+	info := a.chainIdToInfo[chainId]
+	ancestor := head.blockNumber - 1
+	parent := head.parentHash
 
-	// chimpos := common.HexToAddress("0xf39f9ac0b1185929903f2bc8d56aea90108503f5")
+	for {
+		hash, ok := info.hashes[ancestor]
+		if !ok || hash == parent {
+			break
+		}
+		var err error
+		parent, err = getParentHash(ctx, source, ancestor)
+		if err != nil {
+			log.Println("error while looking for reorg ancestor", err)
+			break
+		}
+		ancestor--
+	}
 
-	// contractAddress := common.HexToAddress("0x952BbDED48D7662Abb25A8CdF7541663CA992B88")
-	// query := ethereum.FilterQuery{
-	// 	FromBlock: big.NewInt(int64(startingBlock)),
-	// 	Addresses: []common.Address{chimpos},
-	// }
-	// logsChan := make(chan types.Log)
-	// erc721Abi, err := abi.JSON(strings.NewReader(string(erc721.Erc721ABI)))
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
+	reorg := ReorgMsg{
+		from:  ancestor + 1,
+		to:    info.lastBlock,
+		depth: info.lastBlock - ancestor,
+	}
+	log.Printf("reorg on %s, dropping blocks %d to %d", chainId, reorg.from, reorg.to)
 
-	// logSub := createLogSub(wssclient, logsChan, startingPoint.BlockNumber)
-	// logSub, err := wssclient.SubscribeFilterLogs(context.Background(), query, logs)
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
-	headers := make(chan *types.Header)
+	for number := range info.hashes {
+		if number > ancestor {
+			delete(info.hashes, number)
+		}
+	}
+	info.lastBlock = ancestor
 
-	// client := wssclient.Client()
+	a.broadcast(chainId, reorg)
+}
 
-	sub, err := wssclient.SubscribeNewHead(context.Background(), headers)
-	a.chainIdToInfo[chain.Id].sub = sub
-	if err != nil {
-		log.Println("error creating subscription", err)
-		message := ErrMsg{
-			msg:   "error creating subscription",
-			isErr: true,
+func (a *app) ethClient(ctx context.Context, chain chain) {
+	a.chainIdToInfo[chain.Id].lastBlock = 0
+	a.chainIdToInfo[chain.Id].hashes = make(map[int]common.Hash)
+	a.chainIdToInfo[chain.Id].noBlockReceipts = false
+	if len(a.chainIdToInfo[chain.Id].endpoints) == 0 {
+		a.broadcast(chain.Id, ErrMsg{msg: "no endpoints configured", isErr: true})
+		return
+	}
+	if chain.Record {
+		recorder, err := newRecorder(chain)
+		if err != nil {
+			log.Println("error starting recording", err)
+		} else {
+			a.chainIdToInfo[chain.Id].recorder = recorder
+			defer func() {
+				recorder.close()
+				a.chainIdToInfo[chain.Id].recorder = nil
+			}()
 		}
-		for _, client := range a.chainIdToInfo[chain.Id].connectedClients {
-			if client.program != nil {
-				client.program.Send(message)
-			}
+	}
+	delay := minReconnectDelay
+	attempt := 0
 
+	for {
+		index, endpoint := bestEndpoint(a.chainIdToInfo[chain.Id].endpoints)
+		up, err := a.listen(ctx, chain, index)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		endpoint.observe(0, err)
+
+		// a connection that was up for a while starts the backoff over, one that
+		// drops straight after subscribing keeps backing off
+		if up >= minHealthyConnection {
+			delay = minReconnectDelay
+			attempt = 0
+		}
+		attempt++
+
+		// fail over straight away until every endpoint has had a turn, then
+		// back off before going around again
+		next, _ := bestEndpoint(a.chainIdToInfo[chain.Id].endpoints)
+		if attempt%len(a.chainIdToInfo[chain.Id].endpoints) != 0 && next != index {
+			log.Printf("%s endpoint %s failed, failing over: %v", chain.Name, endpoint.url, err)
+			a.broadcast(chain.Id, ErrMsg{msg: fmt.Sprint("failing over from ", endpoint.url), isErr: true})
+			continue
 		}
-		return
 
+		log.Printf("%s connection lost, reconnecting in %s (attempt %d): %v", chain.Name, delay, attempt, err)
+		a.broadcast(chain.Id, ReconnectMsg{
+			attempt: attempt,
+			delay:   delay,
+			err:     err.Error(),
+		})
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			log.Printf("no clients left on %s, giving up reconnect", chain.Name)
+			return
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// listen opens a source for one of the chain's endpoints, subscribes to new
+// heads and forwards blocks until the subscription ends. up is how long the
+// subscription was established for, zero if it never was, and a nil error
+// means the listener was shut down on purpose.
+func (a *app) listen(ctx context.Context, chain chain, index int) (up time.Duration, err error) {
+	info := a.chainIdToInfo[chain.Id]
+	source, err := dialSource(ctx, info, index)
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+
+	// block and receipt lookups share the chain's batcher with everything
+	// sessions ask for
+	info.rpc.attach(source)
+	defer info.rpc.detach()
+
+	headers := make(chan *types.Header)
+
+	sub, err := source.Heads(ctx, headers)
+	if err != nil {
+		return 0, fmt.Errorf("error subscribing to new heads: %w", err)
+	}
+	defer sub.Unsubscribe()
+	subscribed := time.Now()
+	status := source.Status()
+	a.broadcast(chain.Id, status)
+
+	// a poller picks up the current head on its first call
+	if status.pollInterval == 0 {
+		var startingBlock hexutil.Big
+		if err := info.rpc.Call(ctx, &startingBlock, "eth_blockNumber"); err == nil {
+			a.deliverHead(ctx, info.rpc, startingBlock.ToInt(), chain.Id)
+		}
 	}
 
 	for {
 
 		select {
 
-		case header := <-headers:
-			if len(a.chainIdToInfo[chain.Id].connectedClients) == 0 {
-				// log.Printf("closing %s eth client\n", chain.Name)
-				sub.Unsubscribe()
-				return
-			}
+		case <-ctx.Done():
+			return time.Since(subscribed), nil
 
-			a.getBlock(wssclient, header.Number, chain.Id)
+		case header := <-headers:
+			a.deliverHead(ctx, info.rpc, header.Number, chain.Id)
 
 		case err := <-sub.Err():
 			if err == nil {
 				log.Printf("%s closed", chain.Name)
-				return
+				return time.Since(subscribed), nil
 			}
-			fmt.Println("error in loop", err)
-
-			message := ErrMsg{
-				msg:   "error. press *** to restart",
-				isErr: true,
-			}
-
-			for _, client := range a.chainIdToInfo[chain.Id].connectedClients {
-				if client.program != nil {
-					client.program.Send(message)
-				}
+			return time.Since(subscribed), err
 
-			}
-			a.chainIdToInfo[chain.Id].connectedClients = make(map[uuid.UUID]*client)
-
-			// case err := <-logSub.Err():
-			// 	log.Fatal("log error", err)
-			// case vLog := <-logsChan:
-			// 	transferTopic := "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
-			// 	approvalTopic := "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
-			// 	approvalForAllTopic := "0x17307eab39ab6107e8899845ad3d59bd9653f200f220920489ca2b5937696c31"
-			// 	// transfer := LogTransfer{}
-			// 	// transfer, err := erc721Abi.Unpack("Transfer", vLog.Data)
-			// 	// if err != nil {
-			// 	// 	log.Fatal(err)
-			// 	// }
-			// 	switch vLog.Topics[0].String() {
-			// 	case transferTopic:
-			// 		fmt.Println("transfer", vLog.BlockNumber)
-			// 	case approvalTopic:
-			// 		fmt.Println("approval", vLog.BlockNumber)
-			// 	case approvalForAllTopic:
-			// 		fmt.Println("approval for all", vLog.BlockNumber)
-
-			// 	}
-			// case newTokens := <-tokenTrackingChan:
-			// 	tokenTracking = append(tokenTracking, newTokens...)
-
-			// 	fmt.Println(newTokens)
 		}
 
 	}
 
 }
-
-// func createLogSub(c *ethclient.Client, ch chan types.Log, startingBlock int) ethereum.Subscription {
-
-// 	query := ethereum.FilterQuery{
-// 		FromBlock: big.NewInt(int64(startingBlock)),
-// 		Addresses: tokenTracking,
-// 	}
-
-// 	logSub, err := c.SubscribeFilterLogs(context.Background(), query, ch)
-// 	if err != nil {
-// 		log.Fatal(err)
-// 	}
-
-// 	return logSub
-// }
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// weight given to the newest sample in the latency and error moving averages
+const endpointSmoothing = 0.2
+
+// endpoint's stats are written by the listener and by batches flushing on
+// their own goroutines, mu guards them.
+type endpoint struct {
+	url       string
+	mu        sync.Mutex
+	latency   time.Duration // moving average of successful request round trips
+	errorRate float64       // moving average of failed requests, 0 to 1
+	requests  int
+	succeeded int          // requests the latency average is taken over
+	limiter   *rateLimiter // nil when the chain has no rate limit
+}
+
+// sent whenever the listener has fresh numbers for the endpoint it is using
+type EndpointMsg struct {
+	url              string
+	index, total     int
+	score, errorRate float64
+	latency          time.Duration
+	requests         int
+	pollInterval     time.Duration
+}
+
+// newEndpoints creates the endpoints for urls, rateLimit is requests per second
+// allowed on each of them, 0 for no limit.
+func newEndpoints(urls []string, rateLimit float64) []*endpoint {
+	endpoints := make([]*endpoint, len(urls))
+	for i, url := range urls {
+		endpoints[i] = &endpoint{url: url, limiter: newRateLimiter(rateLimit)}
+	}
+	return endpoints
+}
+
+// observe records a request, a failed one only counts towards the error rate
+// since how long it took to fail says nothing about the endpoint's speed.
+func (e *endpoint) observe(latency time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	failed := 0.0
+	if err != nil {
+		failed = 1
+	}
+
+	if e.requests == 0 {
+		e.errorRate = failed
+	} else {
+		e.errorRate = e.errorRate*(1-endpointSmoothing) + failed*endpointSmoothing
+	}
+	e.requests++
+
+	if err != nil {
+		return
+	}
+	if e.succeeded == 0 {
+		e.latency = latency
+	} else {
+		e.latency = time.Duration(float64(e.latency)*(1-endpointSmoothing) + float64(latency)*endpointSmoothing)
+	}
+	e.succeeded++
+}
+
+// score rates the endpoint from 0 to 100, errors cost up to 70 points and
+// latency up to 30 (one point per 20ms). Unused endpoints score 100.
+func (e *endpoint) score() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.unlockedScore()
+}
+
+func (e *endpoint) unlockedScore() float64 {
+	latencyPenalty := min(float64(e.latency.Milliseconds())/20, 30)
+	return roundFloat(100-e.errorRate*70-latencyPenalty, 1)
+}
+
+// status is the endpoint's stats read together, sources fill in the rest.
+func (e *endpoint) status() EndpointMsg {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return EndpointMsg{
+		url:       e.url,
+		score:     e.unlockedScore(),
+		errorRate: e.errorRate,
+		latency:   e.latency,
+		requests:  e.requests,
+	}
+}
+
+// bestEndpoint returns the highest scoring endpoint, ties go to the one listed
+// first in chains.json.
+func bestEndpoint(endpoints []*endpoint) (int, *endpoint) {
+	best := 0
+	for i, e := range endpoints {
+		if e.score() > endpoints[best].score() {
+			best = i
+		}
+	}
+	return best, endpoints[best]
+}
+
+func (s EndpointMsg) mode() string {
+	if s.pollInterval == 0 {
+		return "subscription"
+	}
+	return fmt.Sprint("polling every ", s.pollInterval)
+}
+
+func (s EndpointMsg) String() string {
+	return fmt.Sprintf("%.1f (%dms, %.0f%% errors)", s.score, s.latency.Milliseconds(), s.errorRate*100)
+}
+
+// rateLimiter spreads requests out to at most rate per second, allowing a
+// second's worth in a burst. Batches bigger than that borrow from the future
+// and the next caller waits the debt off.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: rate, tokens: rate, last: time.Now()}
+}
+
+// wait blocks until n more requests are allowed.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	debt := -l.tokens
+	l.mu.Unlock()
+
+	if debt <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(time.Duration(debt / l.rate * float64(time.Second))):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
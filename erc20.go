@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+)
+
+// selector of decimals()
+var decimalsSelector = crypto.Keccak256([]byte("decimals()"))[:4]
+
+// assumed for tokens whose decimals() can't be read
+const defaultDecimals = 18
+
+// tokenEvent is a Transfer or Approval of a tracked ERC-20 token. For approvals
+// from is the owner and to the spender.
+type tokenEvent struct {
+	name        string
+	token       common.Address
+	approval    bool
+	from, to    common.Address
+	amount      decimal.Decimal
+	txHash      common.Hash
+	blockNumber int
+}
+
+func (e tokenEvent) String() string {
+	kind, arrow := "transfer", "→"
+	if e.approval {
+		kind, arrow = "approval", "⇢"
+	}
+	return fmt.Sprintf("#%d %-12.12s %-8s %s %s %s %s", e.blockNumber, e.name, kind,
+		shortAddress(e.from), arrow, shortAddress(e.to), e.amount.Truncate(4).String())
+}
+
+func shortAddress(address common.Address) string {
+	hex := address.Hex()
+	return hex[:6] + "…" + hex[len(hex)-4:]
+}
+
+// sent to a session with the tracked token events found in a block
+type TokenEventsMsg struct {
+	chainId     string
+	blockNumber int
+	events      []tokenEvent
+	err         error
+}
+
+// tokenDecimals remembers decimals() per token so it's only asked for once per
+// chain, whichever session tracks the token.
+type tokenDecimals struct {
+	mu      sync.Mutex
+	byToken map[common.Address]int
+}
+
+func newTokenDecimals() *tokenDecimals {
+	return &tokenDecimals{byToken: make(map[common.Address]int)}
+}
+
+func (a *app) decimals(ctx context.Context, chainId string, token common.Address) int {
+	info := a.chainIdToInfo[chainId]
+	info.decimals.mu.Lock()
+	decimals, ok := info.decimals.byToken[token]
+	info.decimals.mu.Unlock()
+	if ok {
+		return decimals
+	}
+
+	var result hexutil.Bytes
+	call := map[string]interface{}{"to": token, "data": hexutil.Bytes(decimalsSelector)}
+	if err := info.rpc.Call(ctx, &result, "eth_call", call, "latest"); err != nil {
+		log.Printf("error reading decimals of %s: %v", token, err)
+		return defaultDecimals
+	}
+	decimals = defaultDecimals
+	if len(result) >= 32 {
+		decimals = int(new(big.Int).SetBytes(result[:32]).Int64())
+	}
+
+	info.decimals.mu.Lock()
+	info.decimals.byToken[token] = decimals
+	info.decimals.mu.Unlock()
+	return decimals
+}
+
+// fetchTokenEvents looks for Transfer and Approval logs of the tracked tokens
+// in block. Logs come from the receipts when the chain fetches them, otherwise
+// with eth_getLogs on the block's hash.
+func (m model) fetchTokenEvents(block BlockMsg) tea.Cmd {
+	tracked := m.trackingERC20[m.chain.Id]
+	if len(tracked.addresses) == 0 {
+		return nil
+	}
+	chainId := m.chain.Id
+	names := make(map[common.Address]string, len(tracked.addresses))
+	for i, address := range tracked.addresses {
+		names[address] = tracked.names[i]
+	}
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		logs, err := m.app.blockLogs(ctx, chainId, block, tracked.addresses, []common.Hash{transferTopic, approvalTopic})
+		if err != nil {
+			return TokenEventsMsg{chainId: chainId, blockNumber: block.blockNumber, err: err}
+		}
+
+		var events []tokenEvent
+		for _, l := range logs {
+			name, ok := names[l.Address]
+			if !ok || l.Removed {
+				continue
+			}
+			event := tokenEvent{name: name, token: l.Address, txHash: l.TxHash, blockNumber: block.blockNumber}
+			var amount *big.Int
+			if transfer, ok := parseLogTransfer(l); ok {
+				event.from, event.to, amount = transfer.From, transfer.To, transfer.Tokens
+			} else if approval, ok := parseLogApproval(l); ok {
+				event.approval = true
+				event.from, event.to, amount = approval.TokenOwner, approval.Spender, approval.Tokens
+			} else {
+				continue
+			}
+			event.amount = ToDecimal(amount, m.app.decimals(ctx, chainId, l.Address))
+			events = append(events, event)
+		}
+		return TokenEventsMsg{chainId: chainId, blockNumber: block.blockNumber, events: events}
+	}
+}
+
+// receiptLogs collects the block's logs from its receipts, ok is false when
+// some receipts are missing.
+func receiptLogs(block BlockMsg) (logs []types.Log, ok bool) {
+	for _, tx := range block.transactions {
+		if tx.receipt == nil {
+			return nil, false
+		}
+		logs = append(logs, tx.receipt.logs...)
+	}
+	return logs, true
+}
+
+// blockLogs is block's logs from addresses, taken from its receipts when it
+// has them all, otherwise asked for with eth_getLogs. Receipt logs aren't
+// filtered so callers still check the address and topic of each. Leave topics
+// empty to get every event.
+func (a *app) blockLogs(ctx context.Context, chainId string, block BlockMsg, addresses []common.Address, topics []common.Hash) ([]types.Log, error) {
+	if logs, ok := receiptLogs(block); ok {
+		return logs, nil
+	}
+	filter := map[string]interface{}{
+		"blockHash": block.hash,
+		"address":   addresses,
+	}
+	if len(topics) > 0 {
+		filter["topics"] = [][]common.Hash{topics}
+	}
+	var logs []types.Log
+	if err := a.chainIdToInfo[chainId].rpc.Call(ctx, &logs, "eth_getLogs", filter); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
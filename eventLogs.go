@@ -4,6 +4,13 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	approvalTopic = crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
 )
 
 type LogTransfer struct {
@@ -17,3 +24,27 @@ type LogApproval struct {
 	Spender    common.Address
 	Tokens     *big.Int
 }
+
+// parseLogTransfer decodes an ERC-20 Transfer. ERC-721 transfers share the
+// topic but index the token id as a fourth topic, those aren't matched.
+func parseLogTransfer(l types.Log) (LogTransfer, bool) {
+	if len(l.Topics) != 3 || l.Topics[0] != transferTopic {
+		return LogTransfer{}, false
+	}
+	return LogTransfer{
+		From:   common.BytesToAddress(l.Topics[1].Bytes()),
+		To:     common.BytesToAddress(l.Topics[2].Bytes()),
+		Tokens: new(big.Int).SetBytes(l.Data),
+	}, true
+}
+
+func parseLogApproval(l types.Log) (LogApproval, bool) {
+	if len(l.Topics) != 3 || l.Topics[0] != approvalTopic {
+		return LogApproval{}, false
+	}
+	return LogApproval{
+		TokenOwner: common.BytesToAddress(l.Topics[1].Bytes()),
+		Spender:    common.BytesToAddress(l.Topics[2].Bytes()),
+		Tokens:     new(big.Int).SetBytes(l.Data),
+	}, true
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// EIP-1559 defaults, chains can override them in chains.json
+const (
+	defaultElasticity     = 2
+	defaultFeeDenominator = 8
+)
+
+// effectiveTip is what tx pays per gas on top of baseFee, nil when the block has
+// no base fee.
+func effectiveTip(tx Transaction, baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return nil
+	}
+	tip := new(big.Int).Sub(tx.gasPrice, baseFee)
+	if tx.maxFeePerGas != nil && tx.maxPriorityFeePerGas != nil {
+		tip = new(big.Int).Sub(tx.maxFeePerGas, baseFee)
+		if tx.maxPriorityFeePerGas.Cmp(tip) < 0 {
+			tip = new(big.Int).Set(tx.maxPriorityFeePerGas)
+		}
+	}
+	if tip.Sign() < 0 {
+		return new(big.Int)
+	}
+	return tip
+}
+
+func (c chain) elasticity() uint64 {
+	if c.Elasticity == 0 {
+		return defaultElasticity
+	}
+	return c.Elasticity
+}
+
+func (c chain) feeDenominator() uint64 {
+	if c.FeeDenominator == 0 {
+		return defaultFeeDenominator
+	}
+	return c.FeeDenominator
+}
+
+// nextBaseFee predicts the base fee of the block after block the way EIP-1559
+// adjusts it: up when the block used more than its gas target, down when less.
+func (c chain) nextBaseFee(block BlockMsg) *big.Int {
+	if block.baseFee == nil {
+		return nil
+	}
+	target := block.gasLimit / c.elasticity()
+	if target == 0 || block.gasUsed == target {
+		return new(big.Int).Set(block.baseFee)
+	}
+
+	diff := new(big.Int).SetUint64(block.gasUsed)
+	diff.Sub(diff, new(big.Int).SetUint64(target))
+	delta := new(big.Int).Mul(block.baseFee, diff)
+	delta.Quo(delta, new(big.Int).SetUint64(target))
+	delta.Quo(delta, new(big.Int).SetUint64(c.feeDenominator()))
+	if block.gasUsed > target && delta.Sign() == 0 {
+		delta.SetInt64(1)
+	}
+	return delta.Add(delta, block.baseFee)
+}
+
+// percentile of sorted values, q between 0 and 1.
+func percentile(sorted []*big.Int, q float64) *big.Int {
+	if len(sorted) == 0 {
+		return new(big.Int)
+	}
+	return sorted[int(q*float64(len(sorted)-1))]
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws values oldest first, scaled between their min and max.
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	low, high := slices.Min(values), slices.Max(values)
+	var line strings.Builder
+	for _, value := range values {
+		level := len(sparks) / 2
+		if high > low {
+			level = int((value - low) / (high - low) * float64(len(sparks)-1))
+		}
+		line.WriteRune(sparks[level])
+	}
+	return line.String()
+}
+
+// fullness draws how much of the gas limit a block used as a bar of width
+// cells.
+func fullness(gasUsed, gasLimit uint64, width int) string {
+	filled := 0
+	if gasLimit > 0 {
+		filled = min(int(float64(gasUsed)/float64(gasLimit)*float64(width)+0.5), width)
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
+
+func gwei(wei *big.Int) string {
+	if wei == nil {
+		return "-"
+	}
+	return ToDecimal(wei, 9).Truncate(3).String()
+}
+
+// renderFees is the fee market box: the latest base fee and where it's headed,
+// how full the block was, priority fee percentiles over memory and a
+// sparkline of the base fee.
+func (m *model) renderFees() string {
+	title := lipgloss.NewStyle().Width(m.styles.fees.GetWidth()).Align(lipgloss.Center).Render("fees:")
+
+	blocks := m.memory[m.chain.Id].blocks
+	if blocks == nil || blocks.Len() == 0 {
+		return m.styles.fees.Render(title)
+	}
+	latest := blocks.Front().block
+	if latest.baseFee == nil {
+		return m.styles.fees.Render(fmt.Sprint(title, "\n", "no base fee on this chain"))
+	}
+
+	next := m.chain.nextBaseFee(latest)
+	change := new(big.Float).Quo(new(big.Float).SetInt(new(big.Int).Sub(next, latest.baseFee)), new(big.Float).SetInt(latest.baseFee))
+	changePercent, _ := change.Float64()
+
+	var (
+		tips    []*big.Int
+		history []float64
+	)
+	for i := blocks.Len() - 1; i >= 0; i-- {
+		block := blocks.At(i).block
+		for _, tx := range block.transactions {
+			if tx.priorityFee != nil {
+				tips = append(tips, tx.priorityFee)
+			}
+		}
+		if block.baseFee != nil {
+			fee, _ := new(big.Float).SetInt(block.baseFee).Float64()
+			history = append(history, fee)
+		}
+	}
+	slices.SortFunc(tips, func(a, b *big.Int) int { return a.Cmp(b) })
+
+	gasShare := 0.0
+	if latest.gasLimit > 0 {
+		gasShare = float64(latest.gasUsed) / float64(latest.gasLimit) * 100
+	}
+
+	fees := lipgloss.JoinVertical(
+		lipgloss.Left,
+		fmt.Sprintf("➢ base fee: %s gwei, next block %s gwei (%+.1f%%)", gwei(latest.baseFee), gwei(next), changePercent*100),
+		fmt.Sprintf("➢ fullness: %s %.1f%% of %d gas", fullness(latest.gasUsed, latest.gasLimit, 20), gasShare, latest.gasLimit),
+		fmt.Sprintf("➢ priority fee p10/p50/p90: %s / %s / %s gwei (%d txs)",
+			gwei(percentile(tips, 0.1)), gwei(percentile(tips, 0.5)), gwei(percentile(tips, 0.9)), len(tips)),
+		fmt.Sprintf("➢ base fee over %d blocks: %s", len(history), sparkline(history)),
+	)
+	return m.styles.fees.Render(fmt.Sprint(title, "\n", fees))
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gammazero/deque"
+	"github.com/google/uuid"
+)
+
+// hub keeps track of the clients watching a chain and owns the lifecycle of the
+// listener feeding them. The listener is started when the first client
+// subscribes and cancelled when the last one leaves, and a new listener never
+// starts before the previous one has returned. It also keeps the last blocks the
+// listener sent so clients joining later don't start from nothing.
+type hub struct {
+	mu      sync.Mutex
+	clients map[uuid.UUID]*subscriber
+	listen  func(ctx context.Context)
+	cancel  context.CancelFunc    // nil while no listener is wanted
+	done    chan struct{}         // closed when the last listener returned
+	recent  deque.Deque[BlockMsg] // oldest first, at most maxCachedBlocks
+}
+
+// most blocks kept per chain for clients that join late
+const maxCachedBlocks = 100
+
+func newHub(listen func(ctx context.Context)) *hub {
+	done := make(chan struct{})
+	close(done)
+	return &hub{
+		clients: make(map[uuid.UUID]*subscriber),
+		listen:  listen,
+		done:    done,
+	}
+}
+
+// subscribe adds c to the hub, queueing up to window of the most recent blocks
+// for it first, and reports whether that started a listener.
+func (h *hub) subscribe(c *client, window int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if old, ok := h.clients[c.id]; ok {
+		old.stop()
+	}
+	if h.cancel == nil {
+		// blocks from an earlier listener are too old to be useful
+		h.recent.Clear()
+	}
+	backlog := make([]BlockMsg, 0, min(max(window, 0), h.recent.Len()))
+	for i := h.recent.Len() - cap(backlog); i < h.recent.Len(); i++ {
+		block := h.recent.At(i)
+		// only the newest block needs events and balances fetched for it
+		block.fromBacklog = i < h.recent.Len()-1
+		backlog = append(backlog, block)
+	}
+	h.clients[c.id] = newSubscriber(c, backlog)
+	if h.cancel != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	previous, done := h.done, make(chan struct{})
+	h.cancel, h.done = cancel, done
+
+	go func() {
+		defer close(done)
+		<-previous
+		h.listen(ctx)
+	}()
+	return true
+}
+
+// unsubscribe removes the client and reports whether that stopped the listener.
+func (h *hub) unsubscribe(id uuid.UUID) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub, ok := h.clients[id]
+	if !ok {
+		return false
+	}
+	sub.stop()
+	delete(h.clients, id)
+	if len(h.clients) > 0 || h.cancel == nil {
+		return false
+	}
+
+	h.cancel()
+	h.cancel = nil
+	return true
+}
+
+// broadcast queues msg for every subscribed client, it never waits on a slow
+// session.
+func (h *hub) broadcast(msg tea.Msg) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch msg := msg.(type) {
+	case BlockMsg:
+		h.recent.PushBack(msg)
+		if h.recent.Len() > maxCachedBlocks {
+			h.recent.PopFront()
+		}
+	case ReorgMsg:
+		for h.recent.Len() > 0 && h.recent.Back().blockNumber >= msg.from {
+			h.recent.PopBack()
+		}
+	}
+
+	for _, sub := range h.clients {
+		sub.push(msg)
+	}
+}
+
+// most messages waiting for a single client before old ones are dropped
+const clientQueueSize = 32
+
+// sent to a client before its next message when blocks had to be dropped
+// because it couldn't keep up
+type SkippedMsg struct {
+	blocks int
+}
+
+// subscriber is a client's bounded outbox. A goroutine drains it into the
+// client's program so a session on a bad link only holds up itself.
+type subscriber struct {
+	client  *client
+	mu      sync.Mutex
+	queue   deque.Deque[tea.Msg]
+	skipped int
+	wake    chan struct{}
+	quit    chan struct{}
+}
+
+// newSubscriber starts draining into c, beginning with backlog. The backlog
+// isn't held to clientQueueSize since those blocks are all the client has.
+func newSubscriber(c *client, backlog []BlockMsg) *subscriber {
+	sub := &subscriber{
+		client: c,
+		wake:   make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}
+	for _, block := range backlog {
+		sub.queue.PushBack(block)
+	}
+	if len(backlog) > 0 {
+		sub.wake <- struct{}{}
+	}
+	go sub.run()
+	return sub
+}
+
+// push queues msg, dropping the oldest block or endpoint update when the queue
+// is full. Other messages (errors, reorgs) are only dropped if nothing else can
+// be.
+func (s *subscriber) push(msg tea.Msg) {
+	s.mu.Lock()
+	if s.queue.Len() >= clientQueueSize {
+		drop := s.queue.Index(func(queued tea.Msg) bool {
+			switch queued.(type) {
+			case BlockMsg, EndpointMsg:
+				return true
+			}
+			return false
+		})
+		if _, ok := s.queue.Remove(max(drop, 0)).(BlockMsg); ok {
+			s.skipped++
+		}
+	}
+	s.queue.PushBack(msg)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscriber) next() (tea.Msg, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.skipped > 0 {
+		msg := SkippedMsg{blocks: s.skipped}
+		s.skipped = 0
+		return msg, true
+	}
+	if s.queue.Len() == 0 {
+		return nil, false
+	}
+	return s.queue.PopFront(), true
+}
+
+func (s *subscriber) run() {
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-s.wake:
+		}
+
+		for msg, ok := s.next(); ok; msg, ok = s.next() {
+			if s.client.program != nil {
+				s.client.program.Send(msg)
+			}
+			select {
+			case <-s.quit:
+				return
+			default:
+			}
+		}
+	}
+}
+
+func (s *subscriber) stop() {
+	close(s.quit)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+)
+
+// countingListener blocks until it's cancelled and keeps count of how many
+// listeners started and how many ran at once.
+type countingListener struct {
+	started, running, most atomic.Int32
+}
+
+func (l *countingListener) listen(ctx context.Context) {
+	l.started.Add(1)
+	running := l.running.Add(1)
+	for {
+		most := l.most.Load()
+		if running <= most || l.most.CompareAndSwap(most, running) {
+			break
+		}
+	}
+	<-ctx.Done()
+	l.running.Add(-1)
+}
+
+func newTestClient() *client {
+	return &client{id: uuid.New()}
+}
+
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHubConcurrentSubscribeStartsOneListener(t *testing.T) {
+	var l countingListener
+	h := newHub(l.listen)
+
+	clients := make([]*client, 50)
+	var wg sync.WaitGroup
+	for i := range clients {
+		clients[i] = newTestClient()
+		wg.Add(1)
+		go func(c *client) {
+			defer wg.Done()
+			h.subscribe(c, 10)
+		}(clients[i])
+	}
+	wg.Wait()
+	waitFor(t, "the listener", func() bool { return l.running.Load() == 1 })
+
+	if started := l.started.Load(); started != 1 {
+		t.Fatalf("%d listeners started, want 1", started)
+	}
+
+	for _, c := range clients {
+		h.unsubscribe(c.id)
+	}
+	waitFor(t, "the listener to stop", func() bool { return l.running.Load() == 0 })
+}
+
+func TestHubSubscribeUnsubscribeChurn(t *testing.T) {
+	var l countingListener
+	h := newHub(l.listen)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := newTestClient()
+			for range 50 {
+				h.subscribe(c, 10)
+				h.unsubscribe(c.id)
+			}
+		}()
+	}
+	wg.Wait()
+	waitFor(t, "the last listener to stop", func() bool { return l.running.Load() == 0 })
+
+	if most := l.most.Load(); most > 1 {
+		t.Fatalf("%d listeners ran at once, want at most 1", most)
+	}
+}
+
+func TestHubNewListenerWaitsForPrevious(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	second := make(chan struct{})
+	h := newHub(func(ctx context.Context) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			// still shutting down
+			<-release
+			return
+		}
+		close(second)
+		<-ctx.Done()
+	})
+
+	first := newTestClient()
+	h.subscribe(first, 0)
+	waitFor(t, "the first listener", func() bool { return calls.Load() == 1 })
+	h.unsubscribe(first.id)
+
+	next := newTestClient()
+	if !h.subscribe(next, 0) {
+		t.Fatal("subscribing after the last client left didn't start a listener")
+	}
+	select {
+	case <-second:
+		t.Fatal("second listener started before the first returned")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-second:
+	case <-time.After(2 * time.Second):
+		t.Fatal("second listener didn't start after the first returned")
+	}
+	h.unsubscribe(next.id)
+}
+
+// stalledModel belongs to a program that's never run, so sending to it blocks
+// like a session on a dead link.
+type stalledModel struct{}
+
+func (stalledModel) Init() tea.Cmd                       { return nil }
+func (stalledModel) Update(tea.Msg) (tea.Model, tea.Cmd) { return stalledModel{}, nil }
+func (stalledModel) View() string                        { return "" }
+
+func TestHubBroadcastDoesNotBlockOnStalledSubscriber(t *testing.T) {
+	h := newHub(func(ctx context.Context) { <-ctx.Done() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stalled := &client{id: uuid.New(), program: tea.NewProgram(stalledModel{}, tea.WithContext(ctx))}
+	h.subscribe(stalled, 0)
+	defer h.unsubscribe(stalled.id)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 10 * clientQueueSize {
+			h.broadcast(BlockMsg{blockNumber: i})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("broadcast blocked on a stalled subscriber")
+	}
+
+	h.mu.Lock()
+	sub := h.clients[stalled.id]
+	h.mu.Unlock()
+	sub.mu.Lock()
+	queued := sub.queue.Len()
+	sub.mu.Unlock()
+	if queued > clientQueueSize {
+		t.Fatalf("%d messages queued, want at most %d", queued, clientQueueSize)
+	}
+}
+
+func TestHubLateJoinerGetsNoStaleBacklog(t *testing.T) {
+	h := newHub(func(ctx context.Context) { <-ctx.Done() })
+
+	first := newTestClient()
+	h.subscribe(first, 0)
+	h.broadcast(BlockMsg{blockNumber: 1})
+	h.unsubscribe(first.id)
+
+	next := newTestClient()
+	h.subscribe(next, 10)
+	defer h.unsubscribe(next.id)
+
+	h.mu.Lock()
+	sub := h.clients[next.id]
+	h.mu.Unlock()
+	sub.mu.Lock()
+	queued := sub.queue.Len()
+	sub.mu.Unlock()
+	if queued != 0 {
+		t.Fatalf("%d blocks from the previous listener queued, want 0", queued)
+	}
+}
@@ -50,6 +50,55 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 	fmt.Fprint(w, fn(str))
 }
 
+type txItem struct {
+	tx     Transaction
+	method string
+}
+
+func (i txItem) FilterValue() string { return i.tx.hash.Hex() }
+
+// txDelegate renders a transaction as its short hash over the method it calls.
+type txDelegate struct{}
+
+func (d txDelegate) Height() int                             { return 2 }
+func (d txDelegate) Spacing() int                            { return 0 }
+func (d txDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+func (d txDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	i, ok := listItem.(txItem)
+	if !ok {
+		return
+	}
+
+	status := ""
+	if i.tx.failed() {
+		status = " ✗"
+	}
+	hash := i.tx.hash.Hex()
+	str := fmt.Sprintf("%d. %s...%s\n   %s", index+1, hash[0:10], status, statusStyle.UnsetPaddingLeft().Render(truncate(i.method, 17)))
+
+	if index == m.Index() {
+		str = selectedItemStyle.UnsetPaddingLeft().Render(str)
+	}
+
+	fmt.Fprint(w, str)
+}
+
+func intializeTransactionList() list.Model {
+	transactions := list.New(nil, txDelegate{}, 20, 10)
+	transactions.SetShowTitle(false)
+	transactions.SetShowHelp(false)
+	transactions.SetShowStatusBar(false)
+	transactions.SetShowPagination(false)
+	transactions.SetFilteringEnabled(false)
+	// q and ? belong to the app, ←→ step through blocks on the history page
+	transactions.KeyMap.Quit.SetEnabled(false)
+	transactions.KeyMap.PrevPage.SetEnabled(false)
+	transactions.KeyMap.NextPage.SetEnabled(false)
+	transactions.KeyMap.ShowFullHelp.SetEnabled(false)
+	transactions.KeyMap.CloseFullHelp.SetEnabled(false)
+	return transactions
+}
+
 func intializeChainList(chains []chain) list.Model {
 	var l = make([]list.Item, len(chains))
 
@@ -70,44 +119,50 @@ func intializeChainList(chains []chain) list.Model {
 	return chainList
 }
 
-func (m model) initializeEOAList() list.Model {
+func (m model) initializeTrackerList(title string, tracked tracking) list.Model {
 
-	var l = make([]list.Item, len(m.trackingEOA[m.chain.Id].addresses))
+	var l = make([]list.Item, len(tracked.addresses))
 
 	for i := range l {
-		l[i] = item{name: m.trackingEOA[m.chain.Id].names[i], description: m.trackingEOA[m.chain.Id].addresses[i].String()}
+		l[i] = item{name: tracked.names[i], description: tracked.addresses[i].String()}
 	}
-	eoaList := list.New(l, itemDelegate{}, 50, len(l)+4)
-	eoaList.Title = "EOA addresses being tracked"
-	eoaList.SetShowHelp(false)
-	eoaList.SetShowStatusBar(false)
+	trackerList := list.New(l, itemDelegate{}, 50, len(l)+4)
+	trackerList.Title = title
+	trackerList.SetShowHelp(false)
+	trackerList.SetShowStatusBar(false)
 	// chainList.SetFilteringEnabled(false)
-	eoaList.Styles.Title = lipgloss.NewStyle()
-	eoaList.Styles.TitleBar.Align(lipgloss.Left)
+	trackerList.Styles.Title = lipgloss.NewStyle()
+	trackerList.Styles.TitleBar.Align(lipgloss.Left)
 
 	// chainList.Styles.PaginationStyle = paginationStyle
 	// chainList.Styles.HelpStyle = helpStyle
 
-	return eoaList
+	return trackerList
 }
 
-// func intializeTrackingProfileList(profiles []profile) list.Model {
-// 	var l = make([]list.Item, len(profiles))
+func intializeTrackingProfileList(profiles []profile) list.Model {
+	var l = make([]list.Item, len(profiles))
+
+	for i := range l {
+		l[i] = profileItem(profiles[i])
+	}
+	trackingProfiles := list.New(l, itemDelegate{}, 40, 10)
+	trackingProfiles.Title = "tracking profiles"
 
-// 	for i := range l {
-// 		l[i] = item{name: profiles[i].Name, description: "description"}
-// 	}
-// 	trackingProfiles := list.New(l, itemDelegate{}, 20, len(profiles)+4)
-// 	trackingProfiles.Title = "tracking profiles"
+	trackingProfiles.SetShowHelp(false)
+	trackingProfiles.SetShowStatusBar(false)
+	trackingProfiles.SetFilteringEnabled(false)
+	// q belongs to the app
+	trackingProfiles.KeyMap.Quit.SetEnabled(false)
+	trackingProfiles.Styles.Title = lipgloss.NewStyle()
+	trackingProfiles.Styles.TitleBar.Align(lipgloss.Left)
 
-// 	trackingProfiles.SetShowHelp(false)
-// 	trackingProfiles.SetShowStatusBar(false)
-// 	// chainList.SetFilteringEnabled(false)
-// 	trackingProfiles.Styles.Title = lipgloss.NewStyle()
-// 	trackingProfiles.Styles.TitleBar.Align(lipgloss.Left)
+	// chainList.Styles.PaginationStyle = paginationStyle
+	// chainList.Styles.HelpStyle = helpStyle
 
-// 	// chainList.Styles.PaginationStyle = paginationStyle
-// 	// chainList.Styles.HelpStyle = helpStyle
+	return trackingProfiles
+}
 
-// 	return trackingProfiles
-// }
+func profileItem(p profile) item {
+	return item{name: p.Name, description: fmt.Sprintf("%s, %d accounts", p.kind(), len(p.Accounts))}
+}
@@ -8,10 +8,10 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"math/big"
 	"net"
 	"os"
 	"os/signal"
-	"strconv"
 	"syscall"
 	"time"
 
@@ -33,6 +33,7 @@ import (
 
 	// "github.com/ethereum/go-ethereum/util"
 	"github.com/muesli/termenv"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 const (
@@ -46,49 +47,92 @@ type client struct {
 }
 
 type chain struct {
-	Wss            string `json:"wss"`
-	Name           string `json:"name"`
-	NativeCurrency string `json:"nativeCurrency"`
-	Id             string `json:"id"`
+	Wss            string   `json:"wss"`
+	Endpoints      []string `json:"endpoints"`
+	Name           string   `json:"name"`
+	NativeCurrency string   `json:"nativeCurrency"`
+	Id             string   `json:"id"`
+	Mode           string   `json:"mode"`           // "subscribe" (default) or "poll"
+	PollInterval   string   `json:"pollInterval"`   // e.g. "2s", used when polling
+	Source         string   `json:"source"`         // "rpc" (default), "simulated" or "replay"
+	BlockTime      string   `json:"blockTime"`      // e.g. "2s", used by simulated chains
+	Record         bool     `json:"record"`         // write every block to recordings/
+	Replay         string   `json:"replay"`         // recording played by replay chains
+	ReplaySpeed    float64  `json:"replaySpeed"`    // 1 (default) plays at the recorded pace
+	Receipts       bool     `json:"receipts"`       // fetch receipts for every transaction
+	RateLimit      float64  `json:"rateLimit"`      // most requests per second per endpoint, 0 for none
+	Elasticity     uint64   `json:"elasticity"`     // EIP-1559 gas limit to target ratio, 2 by default
+	FeeDenominator uint64   `json:"feeDenominator"` // EIP-1559 base fee change denominator, 8 by default
+	Multicall      string   `json:"multicall"`      // Multicall3 address, the usual one by default
 	// Metrics        Metrics
 }
 
+const defaultPollInterval = 2 * time.Second
+
 // type TokenTracking struct {
 // 	address
 // }
 
-// type profile struct {
-// 	Name     string `json:"name"`
-// 	Accounts []struct {
-// 		Address string `json:"address"`
-// 		Name    string `json:"name"`
-// 	} `json:"accounts"`
-
-// 	Activity []Transaction
-// }
+// profile is a named set of accounts that can be swapped in for what a chain
+// tracks, kind says which list: "eoa" (default) or "erc721"
+type profile struct {
+	Name     string           `json:"name"`
+	Kind     string           `json:"kind"`
+	Accounts []trackedAddress `json:"accounts"`
+}
 
-// type Profiles struct {
-// 	Profiles []profile `json:"profiles"`
-// }
+// config/profiles.json, every session starts with these unless the user
+// saved their own
+type Profiles struct {
+	Profiles []profile `json:"profiles"`
+}
 
 type Chains struct {
 	Chains []chain `json:"chains"`
 }
 
+// config/tokenTracking.json, the erc721 collections listed there are tracked on
+// every chain until a session changes it in set up. erc20 lists tokens by chain
+// id whose balances are shown for every tracked EOA.
+type TokenTracking struct {
+	ERC721 trackedAddresses            `json:"erc721"`
+	ERC20  map[string]trackedAddresses `json:"erc20"`
+}
+
+type trackedAddresses struct {
+	Addresses []common.Address `json:"addresses"`
+}
+
+func (t trackedAddresses) tracking() tracking {
+	var tracked tracking
+	for _, address := range t.Addresses {
+		tracked.addresses = append(tracked.addresses, address)
+		tracked.names = append(tracked.names, shortAddress(address))
+	}
+	return tracked
+}
+
 type styles struct {
 	center, chainData, chainList, settings, tokenTracking, health lipgloss.Style
+	transactions, fees                                            lipgloss.Style
 }
 
 type screenContent struct {
-	transactions, chainData, about string
-	health                         struct {
+	chainData, about       string
+	reorgs, lastReorgDepth int
+	trackingView           int // erc20, erc721, contract events, eoa activity, balances or portfolio in the token tracking box
+	scroll                 int // lines of the activity feed or portfolio scrolled past
+	health                 struct {
 		connection, errorMesssage string
 		latency                   int64
+		reconnects, skipped       int
+		endpoint                  EndpointMsg
 	}
 }
 
 type memoryBlock struct {
 	blockNumber, transactions, timestamp int
+	block                                BlockMsg // for the block history page
 }
 
 type memory struct {
@@ -97,14 +141,23 @@ type memory struct {
 }
 
 type activity struct {
-	name string
-	tx   Transaction
+	name      string
+	address   common.Address // the tracked address the tx is in or out of
+	tx        Transaction
+	timestamp int64 // of the tx's block
 }
 
 type tracking struct {
 	addresses []common.Address
 	names     []string
 	activity  []activity
+	events    []tokenEvent
+	nfts      []nftEvent
+	contracts []contractEvent
+	balances  map[common.Address]*balanceHistory                 // native balances of tracked eoas
+	fetching  map[common.Address]bool                            // first balance reads still in flight
+	tokens    map[common.Address]tokenInfo                       // erc20 tokens in the portfolio
+	holdings  map[common.Address]map[common.Address]tokenBalance // by tracked eoa then token
 }
 
 const (
@@ -112,6 +165,9 @@ const (
 	About
 	Main
 	SetUp
+	TxDetail
+	BlockHistory
+	TrackingProfiles
 )
 
 //go:embed markdown/*
@@ -122,25 +178,33 @@ type model struct {
 	setUpPage                                  *SetUpPage
 	app                                        *app
 	renderer                                   *lipgloss.Renderer
-	transactions, about                        viewport.Model
-	chainList                                  list.Model
+	about                                      viewport.Model
+	chainList, transactions, blockTxs          list.Model
 	memory                                     map[string]memory
 	screenContent                              screenContent
 	chain                                      chain
 	styles                                     styles
 	client                                     *client
 	trackingEOA, trackingERC20, trackingERC721 map[string]tracking
+	trackingContracts                          map[string]tracking
+	selectedTx                                 Transaction // shown on the tx detail page
+	historyIndex                               int         // block shown on the history page, 0 is the newest
+	user                                       string      // ssh key fingerprint, empty without one
+	profiles                                   []profile
+	profilePage                                profilePage
 }
 
 func initialModel(chains []chain) model {
 	m := model{}
 	m.chainList = intializeChainList(chains)
+	m.transactions = intializeTransactionList()
+	m.blockTxs = intializeTransactionList()
 	m.trackingEOA = make(map[string]tracking)
 	m.trackingERC20 = make(map[string]tracking)
 	m.trackingERC721 = make(map[string]tracking)
+	m.trackingContracts = make(map[string]tracking)
 	m.memory = make(map[string]memory)
 	m.client = &client{id: uuid.New()}
-	// model.trackingProfile = a.profiles[0] // temporary
 
 	// m.memory[m.chain.Id] = memory{
 	// 	blocks: new(deque.Deque[memoryBlock]),
@@ -150,6 +214,7 @@ func initialModel(chains []chain) model {
 	// m.memory[m.chain.Id].blocks.SetBaseCap(m.memory[m.chain.Id].window)
 	m.currentPage = SelectChain
 
+	// until a user saves their own
 	eoaTracking := m.trackingEOA["8453"]
 
 	eoaTracking.addresses = append(eoaTracking.addresses, common.HexToAddress("0xB45A1378e9BBa0eA4ca6435544B62fd23806CD0D"))
@@ -185,8 +250,11 @@ func main() {
 	a.chainIdToInfo = make(map[string]*chainInfo)
 
 	a.configureChains()
+	a.configureTokenTracking()
+	a.abis = newABIRegistry(abiDir)
+	a.settings = &settingsStore{dir: settingsDir}
 
-	// a.configureProfiles()
+	a.configureProfiles()
 
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -196,6 +264,12 @@ func main() {
 	s, err := wish.NewServer(
 		wish.WithAddress(net.JoinHostPort(host, port)),
 		wish.WithHostKeyPath(fmt.Sprint(home, "/.ssh/livethereum/livethereum")),
+		// anyone can connect, a key only lets their settings be remembered
+		wish.WithPublicKeyAuth(func(ssh.Context, ssh.PublicKey) bool { return true }),
+		wish.WithKeyboardInteractiveAuth(func(ctx ssh.Context, _ gossh.KeyboardInteractiveChallenge) bool {
+			ctx.SetValue(keyboardInteractiveKey, true)
+			return true
+		}),
 		wish.WithMiddleware(
 			bubbletea.MiddlewareWithProgramHandler(a.ProgramHandler, termenv.ANSI256),
 			activeterm.Middleware(), // Bubble Tea apps usually require a PTY.
@@ -238,6 +312,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	// var updateTx bool
 
+	// a profile name being typed gets every key
+	if key, ok := msg.(tea.KeyMsg); ok && m.currentPage == TrackingProfiles && m.profilePage.renaming {
+		return m, m.updateRename(key)
+	}
+
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.updateSize(msg)
@@ -265,52 +344,134 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.memory[m.chain.Id] = memory
 
 				trackingStruct := m.trackingEOA[m.chain.Id]
-				trackingStruct.names, trackingStruct.addresses = m.setUpPage.updateEOA()
+				trackingStruct.names, trackingStruct.addresses = m.setUpPage.EOA.tracked()
 				m.trackingEOA[m.chain.Id] = trackingStruct
 
+				tokenTracking := m.trackingERC20[m.chain.Id]
+				tokenTracking.names, tokenTracking.addresses = m.setUpPage.ERC20.tracked()
+				m.trackingERC20[m.chain.Id] = tokenTracking
+
+				nftTracking := m.trackingERC721[m.chain.Id]
+				nftTracking.names, nftTracking.addresses = m.setUpPage.ERC721.tracked()
+				m.trackingERC721[m.chain.Id] = nftTracking
+
+				contractTracking := m.trackingContracts[m.chain.Id]
+				contractTracking.names, contractTracking.addresses = m.setUpPage.Contracts.tracked()
+				m.trackingContracts[m.chain.Id] = contractTracking
+
+				m.saveSettings()
+
 			}
 
 			if m.currentPage == Main {
 				m.clearScreen(msg)
-				m.app.disconnectClient(m.chain, m.client.id, m.app.chainIdToInfo[m.chain.Id].sub)
+				m.app.disconnectClient(m.chain, m.client.id)
 				m.currentPage = SelectChain
+			} else if m.currentPage == BlockHistory {
+				// previousPage is the tx detail page after one was opened
+				m.currentPage = Main
 			} else {
 				m.currentPage = m.previousPage
 			}
 
 			return m, nil
-		case "q", "ctrl+c":
-			m.app.disconnectClient(m.chain, m.client.id, m.app.chainIdToInfo[m.chain.Id].sub)
+		case "b":
+			if m.currentPage == Main {
+				m.historyIndex = 0
+				m.showHistoryBlock()
+				m.previousPage = Main
+				m.currentPage = BlockHistory
+			}
+		case "left", "right":
+			if m.currentPage == BlockHistory {
+				step := 1
+				if msg.String() == "right" {
+					step = -1
+				}
+				m.historyIndex = min(max(m.historyIndex+step, 0), max(m.memory[m.chain.Id].blocks.Len()-1, 0))
+				m.showHistoryBlock()
+			}
+		case "p":
+			if m.currentPage == Main {
+				m.openProfiles()
+				m.previousPage = Main
+				m.currentPage = TrackingProfiles
+			}
+		case "n", "c":
+			if m.currentPage == TrackingProfiles {
+				kind := "eoa"
+				if msg.String() == "c" {
+					kind = "erc721"
+				}
+				return m, m.newProfile(kind)
+			}
+		case "r":
+			if m.currentPage == TrackingProfiles {
+				return m, m.startRename()
+			}
+		case "tab":
+			if m.currentPage == Main {
+				m.screenContent.trackingView = (m.screenContent.trackingView + 1) % 6
+				m.screenContent.scroll = 0
+			}
+		case "pgup", "pgdown":
+			if m.currentPage == Main {
+				step := m.styles.tokenTracking.GetHeight() - 2
+				if msg.String() == "pgup" {
+					step = -step
+				}
+				m.scrollTracking(step)
+			}
+		case "q":
+			// set up is all text inputs, q is just a letter there
+			if m.currentPage != SetUp {
+				m.app.disconnectClient(m.chain, m.client.id)
+				return m, tea.Quit
+			}
+		case "ctrl+c":
+			m.app.disconnectClient(m.chain, m.client.id)
 			return m, tea.Quit
 		case "enter":
 			if m.currentPage == SelectChain {
 				m.previousPage = m.currentPage
 				m.chain = m.app.chains[m.chainList.Index()]
 				if m.memory[m.chain.Id].blocks == nil {
+					// the window may have been loaded from the user's settings
+					window := m.memory[m.chain.Id].window
+					if window == 0 {
+						window = 10
+					}
 					m.memory[m.chain.Id] = memory{
 						blocks: new(deque.Deque[memoryBlock]),
-						window: 10,
+						window: window,
 					}
 
 					m.memory[m.chain.Id].blocks.SetBaseCap(m.memory[m.chain.Id].window)
 				}
 
+				// the hub replays its recent blocks to fill this back up
 				m.memory[m.chain.Id].blocks.Clear()
-				if len(m.app.chainIdToInfo[m.chain.Id].connectedClients) == 0 {
-					log.Printf("starting %s eth client", m.chain.Name)
-					go ethClient(m.app, m.chainList.Index())
-				}
-				log.Printf("adding client %s to %s connection", m.client.id.String(), m.chain.Name)
-				m.app.chainIdToInfo[m.chain.Id].connectedClients[m.client.id] = m.client
+				m.app.connectClient(m.chain, m.client, m.memory[m.chain.Id].window)
 
 				m.currentPage = Main
 				fmt.Println(m.chain.Id)
+			} else if m.currentPage == Main {
+				m.openTxDetail(m.transactions)
+			} else if m.currentPage == BlockHistory {
+				m.openTxDetail(m.blockTxs)
+			} else if m.currentPage == TrackingProfiles {
+				m.applyProfile()
+				m.currentPage = Main
 			}
 
 		}
 
 	case BlockMsg:
 		m.saveMemory(msg)
+		blockNumber := fmt.Sprint("#", msg.blockNumber)
+		if msg.backfilled {
+			blockNumber += " (backfilled)"
+		}
 		var mem string
 		for block := range m.memory[m.chain.Id].blocks.Len() {
 			mem = fmt.Sprint(mem, " ", m.memory[m.chain.Id].blocks.At(block).blockNumber)
@@ -325,22 +486,26 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.screenContent.chainData = lipgloss.JoinVertical(
 			lipgloss.Left,
 			fmt.Sprint("➢ Chain: ", m.chain.Name, " (", m.chain.Id, ")"),
-			fmt.Sprint("➢ Block: #", msg.blockNumber),
+			fmt.Sprint("➢ Block: ", blockNumber),
 			fmt.Sprint("➢ Transactions: ", len(msg.transactions)),
 			fmt.Sprint("➢ Value Transferred: ", ToDecimal(msg.totalValue, 18).Truncate(3), " ", m.chain.NativeCurrency),
 			fmt.Sprint("➢ Time: ", timeStr),
 			fmt.Sprint("➢ Average TPS: ", tps),
 			fmt.Sprint("➢ Average blocktime: ", avgBlocktime, "s"),
+			fmt.Sprint("➢ Reorgs: ", m.screenContent.reorgs, " (last depth ", m.screenContent.lastReorgDepth, ")"),
 		)
 		// cycle through transactions
-		m.screenContent.transactions = ""
-		for index, tx := range msg.transactions {
-			m.appendTx(index, tx.hash)
-			m.checkEOAActivity(common.HexToAddress(tx.from), common.HexToAddress(tx.to), tx)
+		items := make([]list.Item, 0, len(msg.transactions))
+		for _, tx := range msg.transactions {
+			items = m.appendTx(items, tx)
+			m.checkEOAActivity(tx, msg.timestamp.Int64())
 
 		}
 		m.pruneActivity(msg)
-		m.transactions.SetContent(fmt.Sprint(m.screenContent.transactions, "\n"))
+		cmds = append(cmds, m.transactions.SetItems(items))
+		if !msg.fromBacklog {
+			cmds = append(cmds, m.fetchTokenEvents(msg), m.fetchNFTEvents(msg), m.fetchContractEvents(msg), m.fetchBalances(msg), m.fetchPortfolio(msg))
+		}
 
 		m.screenContent.health.latency = time.Now().Unix() - msg.timestamp.Int64()
 
@@ -352,11 +517,107 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.screenContent.health.errorMesssage = msg.msg
 		// }
 
+	case TokenEventsMsg:
+		if msg.chainId != m.chain.Id {
+			break
+		}
+		if msg.err != nil {
+			m.screenContent.health.errorMesssage = fmt.Sprint("error fetching token logs: ", msg.err)
+			break
+		}
+		tokenTracking := m.trackingERC20[m.chain.Id]
+		tokenTracking.events = append(tokenTracking.events, msg.events...)
+		m.trackingERC20[m.chain.Id] = tokenTracking
+
+	case NFTEventsMsg:
+		if msg.chainId != m.chain.Id {
+			break
+		}
+		if msg.err != nil {
+			m.screenContent.health.errorMesssage = fmt.Sprint("error fetching collection logs: ", msg.err)
+			break
+		}
+		nftTracking := m.trackingERC721[m.chain.Id]
+		nftTracking.nfts = append(nftTracking.nfts, msg.events...)
+		m.trackingERC721[m.chain.Id] = nftTracking
+
+	case ContractEventsMsg:
+		if msg.chainId != m.chain.Id {
+			break
+		}
+		if msg.err != nil {
+			m.screenContent.health.errorMesssage = fmt.Sprint("error fetching contract logs: ", msg.err)
+			break
+		}
+		contractTracking := m.trackingContracts[m.chain.Id]
+		contractTracking.contracts = append(contractTracking.contracts, msg.events...)
+		m.trackingContracts[m.chain.Id] = contractTracking
+
+	case BalancesMsg:
+		for _, address := range msg.addresses {
+			delete(m.trackingEOA[msg.chainId].fetching, address)
+		}
+		if msg.chainId != m.chain.Id {
+			break
+		}
+		if msg.err != nil {
+			m.screenContent.health.errorMesssage = fmt.Sprint("error fetching balances: ", msg.err)
+			break
+		}
+		m.saveBalances(msg)
+
+	case PortfolioMsg:
+		if msg.chainId != m.chain.Id {
+			break
+		}
+		if msg.err != nil {
+			m.screenContent.health.errorMesssage = fmt.Sprint("error fetching token balances: ", msg.err)
+			break
+		}
+		m.savePortfolio(msg)
+
+	case EndpointMsg:
+		m.screenContent.health.endpoint = msg
+
+	case ReorgMsg:
+		m.rollbackMemory(msg)
+		m.screenContent.reorgs++
+		m.screenContent.lastReorgDepth = msg.depth
+
+	case SkippedMsg:
+		m.screenContent.health.skipped += msg.blocks
+		m.screenContent.health.errorMesssage = fmt.Sprintf("%d blocks skipped, connection too slow", msg.blocks)
+
+	case ReconnectMsg:
+		m.screenContent.health.connection = fmt.Sprintf("reconnecting in %s (attempt %d)", msg.delay, msg.attempt)
+		m.screenContent.health.errorMesssage = msg.err
+		m.screenContent.health.reconnects++
+
 	case tea.MouseMsg:
-		//
-		if msg.X < 21 && msg.Y > 10 {
-			m.transactions, cmd = m.transactions.Update(msg)
-			cmds = append(cmds, cmd)
+		// the transaction list is the bottom left box, every transaction
+		// takes two lines below its top border
+		if m.currentPage == Main && msg.X < m.styles.transactions.GetWidth()+2 && msg.Y > 12 {
+			switch {
+			case msg.Button == tea.MouseButtonWheelUp:
+				m.transactions.CursorUp()
+			case msg.Button == tea.MouseButtonWheelDown:
+				m.transactions.CursorDown()
+			case msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionPress:
+				row := (msg.Y - 13) / 2
+				if row < m.transactions.Paginator.ItemsOnPage(len(m.transactions.Items())) {
+					m.transactions.Select(m.transactions.Paginator.Page*m.transactions.Paginator.PerPage + row)
+					m.openTxDetail(m.transactions)
+				}
+			}
+		}
+		// the token tracking box is right of it, under the top boxes
+		if m.currentPage == Main && msg.X >= m.styles.transactions.GetWidth()+2 && msg.Y > 12 {
+			switch msg.Button {
+			case tea.MouseButtonWheelUp:
+				m.scrollTracking(-1)
+			case tea.MouseButtonWheelDown:
+				m.scrollTracking(1)
+			}
 		}
 
 	}
@@ -370,6 +631,21 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, cmd)
 	case SetUp:
 		cmds = append(cmds, m.setUpPage.update(msg))
+	case Main:
+		if _, ok := msg.(tea.KeyMsg); ok {
+			m.transactions, cmd = m.transactions.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+	case BlockHistory:
+		if _, ok := msg.(tea.KeyMsg); ok {
+			m.blockTxs, cmd = m.blockTxs.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+	case TrackingProfiles:
+		if _, ok := msg.(tea.KeyMsg); ok {
+			m.profilePage.list, cmd = m.profilePage.list.Update(msg)
+			cmds = append(cmds, cmd)
+		}
 
 	}
 
@@ -391,15 +667,28 @@ func (m model) View() string {
 		tokenTracking := m.renderTokenTracking()
 		topBoxes := lipgloss.JoinHorizontal(lipgloss.Left, chainData, settings, health)
 
-		bottomBoxes := lipgloss.JoinHorizontal(lipgloss.Left, transactions, tokenTracking)
+		fees := m.renderFees()
+		bottomBoxes := lipgloss.JoinHorizontal(lipgloss.Left, transactions, lipgloss.JoinVertical(lipgloss.Left, tokenTracking, fees))
 
-		help := m.styles.center.AlignVertical(lipgloss.Bottom).Foreground(lipgloss.Color("#808080")).Render("'ctrl+z' back      'ctrl+a' about      'ctrl+s' set up")
+		help := m.styles.center.AlignVertical(lipgloss.Bottom).Foreground(lipgloss.Color("#808080")).Render("'ctrl+z' back      'ctrl+a' about      'ctrl+s' set up      'tab' next tracking view      'b' blocks      'p' profiles")
 
 		return lipgloss.JoinVertical(lipgloss.Left, title, topBoxes, bottomBoxes, help)
 
 	case About:
 		help := m.styles.center.AlignVertical(lipgloss.Bottom).Foreground(lipgloss.Color("#808080")).Render("'ctrl+z' back")
 		return lipgloss.JoinVertical(lipgloss.Center, m.about.View(), help)
+	case BlockHistory:
+		help := m.styles.center.AlignVertical(lipgloss.Bottom).Foreground(lipgloss.Color("#808080")).Render("'ctrl+z' back      '←→' older/newer block      '↑↓' select      'enter' transaction")
+		return lipgloss.JoinVertical(lipgloss.Center, m.renderBlockHistory(), help)
+	case TrackingProfiles:
+		help := m.styles.center.AlignVertical(lipgloss.Bottom).Foreground(lipgloss.Color("#808080")).Render("'ctrl+z' back      'enter' use on this chain      'n' new from eoas      'c' new from collections      'r' rename")
+		if m.profilePage.renaming {
+			help = m.styles.center.AlignVertical(lipgloss.Bottom).Foreground(lipgloss.Color("#808080")).Render("'enter' save name      'esc' cancel")
+		}
+		return lipgloss.JoinVertical(lipgloss.Center, m.renderProfiles(), help)
+	case TxDetail:
+		help := m.styles.center.AlignVertical(lipgloss.Bottom).Foreground(lipgloss.Color("#808080")).Render("'ctrl+z' back")
+		return lipgloss.JoinVertical(lipgloss.Center, m.renderTxDetail(), help)
 	case SetUp:
 		setUp := m.renderSetUp()
 		help := m.styles.center.AlignVertical(lipgloss.Bottom).Foreground(lipgloss.Color("#808080")).Render(m.setUpPage.help)
@@ -415,17 +704,17 @@ func (m *model) updateSize(msg tea.WindowSizeMsg) {
 
 	m.width, m.height = msg.Width, msg.Height
 	m.styles.center = m.renderer.NewStyle().Width(m.width).Align(lipgloss.Center)
-	m.styles.chainData = m.renderer.NewStyle().Width(35).Height(8).Border(lipgloss.NormalBorder())
-	m.styles.settings = m.renderer.NewStyle().Width(25).Height(8).Border(lipgloss.NormalBorder())
+	m.styles.chainData = m.renderer.NewStyle().Width(35).Height(9).Border(lipgloss.NormalBorder())
+	m.styles.settings = m.renderer.NewStyle().Width(25).Height(9).Border(lipgloss.NormalBorder())
 	m.styles.tokenTracking = m.renderer.NewStyle().Width(82).Height(8).Border(lipgloss.NormalBorder())
-	m.styles.health = m.renderer.NewStyle().Width(40).Height(8).Border(lipgloss.NormalBorder())
-	// m.trackingProfiles = viewport.New(20, 6)
+	m.styles.health = m.renderer.NewStyle().Width(40).Height(9).Border(lipgloss.NormalBorder())
+	m.styles.fees = m.renderer.NewStyle().Width(82).Height(5).Border(lipgloss.NormalBorder())
 
 	m.styles.chainList = m.renderer.NewStyle().Width(m.width).Height(m.height-5).Align(lipgloss.Center, lipgloss.Center)
-	m.transactions = viewport.New(22, m.height-12)
-	m.transactions.YPosition = 10
-	m.transactions.Style = m.renderer.NewStyle().Border(lipgloss.NormalBorder())
-	m.transactions.SetContent(fmt.Sprint(m.screenContent.transactions, "\n"))
+	m.styles.transactions = m.renderer.NewStyle().Width(20).Height(m.height - 15).Border(lipgloss.NormalBorder())
+	m.transactions.SetSize(m.styles.transactions.GetWidth(), m.styles.transactions.GetHeight())
+	// what's left on the history page under the block's ten lines of details
+	m.blockTxs.SetSize(m.styles.transactions.GetWidth(), max(m.height-16, 2))
 	m.about = viewport.New(m.width, m.height-1)
 	// m.setUpPage.container = viewport.New(m.width/2, m.height-2)
 	m.setUpPage.container.Height = m.height - 2
@@ -450,7 +739,8 @@ func (m *model) renderSettings() string {
 		fmt.Sprintf("➢ memory: %d blocks", m.memory[m.chain.Id].window),
 		fmt.Sprintf("➢ tracking  %d EOA addresses", len(m.trackingEOA[m.chain.Id].addresses)),
 		fmt.Sprintf("➢ tracking  %d ERC20 addresses", len(m.trackingERC20[m.chain.Id].addresses)),
-		fmt.Sprintf("➢ tracking  %d ERC721 addresses", len(m.trackingERC721[m.chain.Id].addresses)))
+		fmt.Sprintf("➢ tracking  %d ERC721 addresses", len(m.trackingERC721[m.chain.Id].addresses)),
+		fmt.Sprintf("➢ tracking  %d contracts", len(m.trackingContracts[m.chain.Id].addresses)))
 
 	return m.styles.settings.Render(fmt.Sprint(title, "\n", settings))
 
@@ -458,21 +748,83 @@ func (m *model) renderSettings() string {
 
 func (m *model) renderTokenTracking() string {
 
-	title := lipgloss.NewStyle().Width(m.styles.tokenTracking.GetWidth()).Align(lipgloss.Center).Render("token tracking:")
+	title := lipgloss.NewStyle().Width(m.styles.tokenTracking.GetWidth()).Align(lipgloss.Center).Render([]string{"token tracking: erc20", "token tracking: erc721", "token tracking: contract events", "tracked eoa activity", "tracked eoa balances", "tracked eoa erc20 portfolio"}[m.screenContent.trackingView])
+
+	var txs, failed int
+	for _, activity := range m.trackingEOA[m.chain.Id].activity {
+		txs++
+		if activity.tx.failed() {
+			failed++
+		}
+	}
+	summary := fmt.Sprintf("tracked activity: %d transactions, %d failed ✗", txs, failed)
 
-	return m.styles.tokenTracking.Render(fmt.Sprint(title, "\n", "hellooooooooo"))
+	lines := []string{summary}
+	room := m.styles.tokenTracking.GetHeight() - 1
+
+	switch m.screenContent.trackingView {
+	case 0:
+		// newest first, as many as fit under the summary
+		events := m.trackingERC20[m.chain.Id].events
+		for i := len(events) - 1; i >= 0 && len(lines) < room; i-- {
+			lines = append(lines, events[i].String())
+		}
+	case 1:
+		// newest first per collection, each collection gets its own heading
+		tracked := m.trackingERC721[m.chain.Id]
+		for i, collection := range tracked.addresses {
+			var events []nftEvent
+			for _, event := range tracked.nfts {
+				if event.collection == collection {
+					events = append(events, event)
+				}
+			}
+			if len(events) == 0 || len(lines) >= room-1 {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s (%d events)", tracked.names[i], len(events)))
+			for j := len(events) - 1; j >= 0 && len(lines) < room; j-- {
+				lines = append(lines, fmt.Sprint("  ", events[j]))
+			}
+		}
+	case 2:
+		events := m.trackingContracts[m.chain.Id].contracts
+		for i := len(events) - 1; i >= 0 && len(lines) < room; i-- {
+			lines = append(lines, events[i].String())
+		}
+	case 3, 5:
+		lines[0] += "   'pgup/pgdown' scroll"
+		all := m.scrollable()
+		scroll := min(m.screenContent.scroll, max(len(all)-(room-1), 0))
+		lines = append(lines, all[scroll:min(scroll+room-1, len(all))]...)
+	case 4:
+		lines = append(lines, m.renderBalances(room-1)...)
+	}
+
+	return m.styles.tokenTracking.Render(fmt.Sprint(title, "\n", lipgloss.JoinVertical(lipgloss.Left, lines...)))
 
 }
 
 func (m *model) renderTransactions() string {
 
-	return m.transactions.View()
+	return m.styles.transactions.Render(m.transactions.View())
 
 }
 func (m *model) renderHealth() string {
 	title := lipgloss.NewStyle().Width(m.styles.health.GetWidth()).Align(lipgloss.Center).Render("connection")
 
-	content := fmt.Sprintf("status: %s\nurl: %s\nlatency: %ds\nmessage: %s", m.screenContent.health.connection, m.chain.Wss, m.screenContent.health.latency, m.screenContent.health.errorMesssage)
+	health := m.screenContent.health
+	content := fmt.Sprintf(
+		"status: %s\nurl (%d/%d): %s\nmode: %s\nscore: %s\nlatency: %ds\nreconnects: %d\nskipped: %d blocks\nmessage: %s",
+		health.connection,
+		health.endpoint.index+1, health.endpoint.total, health.endpoint.url,
+		health.endpoint.mode(),
+		health.endpoint,
+		health.latency,
+		health.reconnects,
+		health.skipped,
+		health.errorMesssage,
+	)
 	return m.styles.health.Render(fmt.Sprint(title, "\n", content))
 
 }
@@ -497,6 +849,65 @@ func (m *model) pruneActivity(msg BlockMsg) {
 		m.trackingEOA[m.chain.Id] = eoaTracking
 	}
 
+	if len(m.trackingERC20[m.chain.Id].events) > 0 {
+		tokenTracking := m.trackingERC20[m.chain.Id]
+		tokenTracking.events = filter(tokenTracking.events, func(event tokenEvent) bool {
+			return event.blockNumber > msg.blockNumber-m.memory[m.chain.Id].window
+		})
+		m.trackingERC20[m.chain.Id] = tokenTracking
+	}
+
+	if len(m.trackingERC721[m.chain.Id].nfts) > 0 {
+		nftTracking := m.trackingERC721[m.chain.Id]
+		nftTracking.nfts = filter(nftTracking.nfts, func(event nftEvent) bool {
+			return event.blockNumber > msg.blockNumber-m.memory[m.chain.Id].window
+		})
+		m.trackingERC721[m.chain.Id] = nftTracking
+	}
+
+	if len(m.trackingContracts[m.chain.Id].contracts) > 0 {
+		contractTracking := m.trackingContracts[m.chain.Id]
+		contractTracking.contracts = filter(contractTracking.contracts, func(event contractEvent) bool {
+			return event.blockNumber > msg.blockNumber-m.memory[m.chain.Id].window
+		})
+		m.trackingContracts[m.chain.Id] = contractTracking
+	}
+
+}
+
+// rollbackMemory forgets blocks and tracked activity orphaned by a reorg.
+func (m *model) rollbackMemory(msg ReorgMsg) {
+	blocks := m.memory[m.chain.Id].blocks
+	for blocks != nil && blocks.Len() > 0 && blocks.Front().blockNumber >= msg.from {
+		blocks.PopFront()
+	}
+
+	eoaTracking := m.trackingEOA[m.chain.Id]
+	eoaTracking.activity = filter(eoaTracking.activity, func(activity activity) bool {
+		return activity.tx.blockNumber < msg.from
+	})
+	m.trackingEOA[m.chain.Id] = eoaTracking
+
+	tokenTracking := m.trackingERC20[m.chain.Id]
+	tokenTracking.events = filter(tokenTracking.events, func(event tokenEvent) bool {
+		return event.blockNumber < msg.from
+	})
+	m.trackingERC20[m.chain.Id] = tokenTracking
+
+	nftTracking := m.trackingERC721[m.chain.Id]
+	nftTracking.nfts = filter(nftTracking.nfts, func(event nftEvent) bool {
+		return event.blockNumber < msg.from
+	})
+	m.trackingERC721[m.chain.Id] = nftTracking
+
+	contractTracking := m.trackingContracts[m.chain.Id]
+	contractTracking.contracts = filter(contractTracking.contracts, func(event contractEvent) bool {
+		return event.blockNumber < msg.from
+	})
+	m.trackingContracts[m.chain.Id] = contractTracking
+
+	m.rollbackBalances(msg.from)
+	m.rollbackPortfolio(msg.from)
 }
 
 func (m *model) saveMemory(msg BlockMsg) {
@@ -505,20 +916,26 @@ func (m *model) saveMemory(msg BlockMsg) {
 		blockNumber:  msg.blockNumber,
 		transactions: len(msg.transactions),
 		timestamp:    int(msg.timestamp.Int64()),
+		block:        msg,
 	})
 
 	if m.memory[m.chain.Id].blocks.Len() > m.memory[m.chain.Id].window {
 		m.memory[m.chain.Id].blocks.PopBack()
 	}
 
+	// keep the history page on the block it's showing
+	if m.currentPage == BlockHistory || m.previousPage == BlockHistory {
+		m.historyIndex = min(m.historyIndex+1, m.memory[m.chain.Id].blocks.Len()-1)
+	}
+
 }
 
-func (m *model) checkEOAActivity(from, to common.Address, tx Transaction) {
+func (m *model) checkEOAActivity(tx Transaction, timestamp int64) {
 	for index, address := range m.trackingEOA[m.chain.Id].addresses {
 		// trackedAddr := tracked.address
-		if from == address || to == address {
+		if tx.from == address || (tx.to != nil && *tx.to == address) {
 			eoaTracking := m.trackingEOA[m.chain.Id]
-			eoaTracking.activity = append(m.trackingEOA[m.chain.Id].activity, activity{name: m.trackingEOA[m.chain.Id].names[index], tx: tx})
+			eoaTracking.activity = append(m.trackingEOA[m.chain.Id].activity, activity{name: m.trackingEOA[m.chain.Id].names[index], address: address, tx: tx, timestamp: timestamp})
 			m.trackingEOA[m.chain.Id] = eoaTracking
 		}
 
@@ -550,17 +967,160 @@ func (m model) blocktime() float64 {
 	return roundFloat(blocktime, 2)
 }
 
-func (m *model) appendTx(index int, hash string) {
-	if len(hash) < 10 {
+func (m *model) appendTx(items []list.Item, tx Transaction) []list.Item {
+	return append(items, txItem{tx: tx, method: m.app.abis.method(tx)})
+}
+
+// openTxDetail shows the transaction selected in transactions, ctrl+z comes
+// back to the current page.
+func (m *model) openTxDetail(transactions list.Model) {
+	selected, ok := transactions.SelectedItem().(txItem)
+	if !ok {
+		return
+	}
+	m.selectedTx = selected.tx
+	m.previousPage = m.currentPage
+	m.currentPage = TxDetail
+}
+
+// showHistoryBlock fills the history page's transaction list with the block
+// at historyIndex.
+func (m *model) showHistoryBlock() {
+	blocks := m.memory[m.chain.Id].blocks
+	if blocks == nil || m.historyIndex >= blocks.Len() {
+		m.blockTxs.SetItems(nil)
 		return
 	}
-	m.screenContent.transactions += fmt.Sprint(strconv.Itoa(index+1) + ". " + hash[0:10] + "...\n")
+	items := make([]list.Item, 0, len(blocks.At(m.historyIndex).block.transactions))
+	for _, tx := range blocks.At(m.historyIndex).block.transactions {
+		items = m.appendTx(items, tx)
+	}
+	m.blockTxs.SetItems(items)
+	m.blockTxs.Select(0)
+}
+
+// renderBlockHistory lists the blocks in memory next to the one selected and
+// its transactions.
+func (m *model) renderBlockHistory() string {
+	title := m.styles.center.Render("Blocks")
+	blocks := m.memory[m.chain.Id].blocks
+	height := m.height - 4
+
+	var numbers []string
+	for i := 0; blocks != nil && i < blocks.Len(); i++ {
+		line := fmt.Sprintf("  #%d (%d txs)", blocks.At(i).blockNumber, blocks.At(i).transactions)
+		if i == m.historyIndex {
+			line = selectedItemStyle.UnsetPaddingLeft().Render(fmt.Sprintf("> #%d (%d txs)", blocks.At(i).blockNumber, blocks.At(i).transactions))
+		}
+		numbers = append(numbers, line)
+	}
+	list := m.renderer.NewStyle().Width(24).Height(height).Border(lipgloss.NormalBorder()).Render(lipgloss.JoinVertical(lipgloss.Left, numbers...))
+
+	if blocks == nil || m.historyIndex >= blocks.Len() {
+		return lipgloss.JoinVertical(lipgloss.Center, title, list)
+	}
+	block := blocks.At(m.historyIndex).block
+
+	baseFee := "none (pre London)"
+	if block.baseFee != nil {
+		baseFee = fmt.Sprint(ToDecimal(block.baseFee, 9).Truncate(4), " gwei")
+	}
+	gasShare := 0.0
+	if block.gasLimit > 0 {
+		gasShare = float64(block.gasUsed) / float64(block.gasLimit) * 100
+	}
+	details := lipgloss.JoinVertical(lipgloss.Left,
+		fmt.Sprint("block:     #", block.blockNumber),
+		fmt.Sprint("hash:      ", block.hash.Hex()),
+		fmt.Sprint("parent:    ", block.parentHash.Hex()),
+		fmt.Sprint("time:      ", time.Unix(block.timestamp.Int64(), 0).Local().Format("2006-01-02 15:04:05")),
+		fmt.Sprint("miner:     ", block.miner.Hex()),
+		fmt.Sprintf("gas used:  %d / %d (%.1f%%)", block.gasUsed, block.gasLimit, gasShare),
+		fmt.Sprint("base fee:  ", baseFee),
+		fmt.Sprint("size:      ", block.size, " bytes"),
+		fmt.Sprint("value:     ", ToDecimal(block.totalValue, 18).Truncate(3), " ", m.chain.NativeCurrency),
+		fmt.Sprint("txs:       ", len(block.transactions)),
+	)
+	transactions := m.styles.transactions.Height(m.blockTxs.Height()).Render(m.blockTxs.View())
+
+	right := m.renderer.NewStyle().Width(m.width - 28).Height(height).Border(lipgloss.NormalBorder()).Render(lipgloss.JoinVertical(lipgloss.Left, details, transactions))
+	return lipgloss.JoinVertical(lipgloss.Center, title, lipgloss.JoinHorizontal(lipgloss.Top, list, right))
+}
+
+// renderTxDetail shows everything known about the selected transaction: the
+// transaction itself, its decoded call and, when receipts are fetched, what it
+// did.
+func (m *model) renderTxDetail() string {
+	title := m.styles.center.Render("Transaction")
+	tx := m.selectedTx
+
+	to := "contract creation"
+	if tx.to != nil {
+		to = tx.to.Hex()
+	}
+	lines := []string{
+		fmt.Sprint("hash:      ", tx.hash.Hex()),
+		fmt.Sprint("block:     ", tx.blockNumber, " (index ", tx.index, ")"),
+		fmt.Sprint("from:      ", tx.from.Hex()),
+		fmt.Sprint("to:        ", to),
+		fmt.Sprint("value:     ", ToDecimal(tx.value, 18), " ", m.chain.NativeCurrency),
+		fmt.Sprint("nonce:     ", tx.nonce),
+		fmt.Sprint("type:      ", tx.txType),
+		fmt.Sprint("gas limit: ", tx.gas),
+		fmt.Sprint("gas price: ", ToDecimal(tx.gasPrice, 9), " gwei"),
+	}
+	if tx.priorityFee != nil {
+		lines = append(lines, fmt.Sprint("tip:       ", ToDecimal(tx.priorityFee, 9), " gwei"))
+	}
+	if tx.maxFeePerGas != nil {
+		lines = append(lines,
+			fmt.Sprint("max fee:   ", ToDecimal(tx.maxFeePerGas, 9), " gwei"),
+			fmt.Sprint("max tip:   ", ToDecimal(tx.maxPriorityFeePerGas, 9), " gwei"),
+		)
+	}
+
+	lines = append(lines, "", fmt.Sprint("method:    ", m.app.abis.method(tx)))
+	if call, ok := m.app.abis.decodeCall(tx.input); ok {
+		for _, field := range call.fields {
+			lines = append(lines, fmt.Sprintf("  %s: %s", field.name, field.value))
+		}
+	}
+	if len(tx.input) > 0 {
+		lines = append(lines, fmt.Sprintf("input:     0x%x", tx.input))
+	}
+
+	lines = append(lines, "")
+	if receipt := tx.receipt; receipt != nil {
+		status := "success"
+		if tx.failed() {
+			status = "reverted ✗"
+		}
+		fee := new(big.Int).Mul(new(big.Int).SetUint64(receipt.gasUsed), receipt.effectiveGasPrice)
+		lines = append(lines,
+			fmt.Sprint("status:    ", status),
+			fmt.Sprintf("gas used:  %d (%.1f%% of limit)", receipt.gasUsed, float64(receipt.gasUsed)/float64(max(tx.gas, 1))*100),
+			fmt.Sprint("effective: ", ToDecimal(receipt.effectiveGasPrice, 9), " gwei"),
+			fmt.Sprint("fee:       ", ToDecimal(fee, 18), " ", m.chain.NativeCurrency),
+			fmt.Sprint("logs:      ", len(receipt.logs)),
+		)
+		for _, l := range receipt.logs {
+			if decoded, ok := m.app.abis.decode(l); ok {
+				lines = append(lines, fmt.Sprint("  ", shortAddress(l.Address), " ", decoded))
+			} else if len(l.Topics) > 0 {
+				lines = append(lines, fmt.Sprint("  ", shortAddress(l.Address), " ", l.Topics[0].TerminalString()))
+			}
+		}
+	} else {
+		lines = append(lines, "no receipt, turn on receipts for the chain to see status, gas used and logs")
+	}
 
+	body := m.renderer.NewStyle().Width(m.width - 2).Height(m.height - 4).Border(lipgloss.NormalBorder()).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+	return lipgloss.JoinVertical(lipgloss.Center, title, body)
 }
 
 func (m *model) clearScreen(msg tea.Msg) {
 	m.screenContent = screenContent{}
-	m.transactions.SetContent("")
+	m.transactions.SetItems(nil)
 	// m.transactions.Update(msg)
 
 }
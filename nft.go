@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	erc721 "livethereum/eventListener"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var approvalForAllTopic = crypto.Keccak256Hash([]byte("ApprovalForAll(address,address,bool)"))
+
+// nftEvent is a mint, burn, transfer or approval in a tracked ERC-721
+// collection. For approvals from is the owner and to the approved address or
+// operator.
+type nftEvent struct {
+	name        string
+	collection  common.Address
+	kind        string
+	from, to    common.Address
+	tokenId     *big.Int // nil for approval for all
+	approved    bool     // approval for all granted or revoked
+	txHash      common.Hash
+	blockNumber int
+}
+
+func (e nftEvent) String() string {
+	switch e.kind {
+	case "mint":
+		return fmt.Sprintf("#%d mint     %s → %s", e.blockNumber, e.token(), shortAddress(e.to))
+	case "burn":
+		return fmt.Sprintf("#%d burn     %s by %s", e.blockNumber, e.token(), shortAddress(e.from))
+	case "approval for all":
+		verb := "revoked"
+		if e.approved {
+			verb = "approved"
+		}
+		return fmt.Sprintf("#%d operator %s %s for %s", e.blockNumber, shortAddress(e.to), verb, shortAddress(e.from))
+	}
+	return fmt.Sprintf("#%d %-8s %s %s → %s", e.blockNumber, e.kind, e.token(), shortAddress(e.from), shortAddress(e.to))
+}
+
+func (e nftEvent) token() string {
+	return fmt.Sprint("id ", e.tokenId)
+}
+
+// sent to a session with the tracked collection events found in a block
+type NFTEventsMsg struct {
+	chainId     string
+	blockNumber int
+	events      []nftEvent
+	err         error
+}
+
+// fetchNFTEvents looks for Transfer, Approval and ApprovalForAll logs of the
+// tracked collections in block, the same way fetchTokenEvents does for ERC-20.
+func (m model) fetchNFTEvents(block BlockMsg) tea.Cmd {
+	tracked := m.trackingERC721[m.chain.Id]
+	if len(tracked.addresses) == 0 {
+		return nil
+	}
+	chainId := m.chain.Id
+	filterers := make(map[common.Address]*erc721.Erc721Filterer, len(tracked.addresses))
+	names := make(map[common.Address]string, len(tracked.addresses))
+	for i, address := range tracked.addresses {
+		// parsing doesn't need a backend
+		filterers[address], _ = erc721.NewErc721Filterer(address, nil)
+		names[address] = tracked.names[i]
+	}
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		logs, err := m.app.blockLogs(ctx, chainId, block, tracked.addresses, []common.Hash{transferTopic, approvalTopic, approvalForAllTopic})
+		if err != nil {
+			return NFTEventsMsg{chainId: chainId, blockNumber: block.blockNumber, err: err}
+		}
+
+		var events []nftEvent
+		for _, l := range logs {
+			filterer, ok := filterers[l.Address]
+			if !ok || l.Removed {
+				continue
+			}
+			event, ok := parseNFTEvent(filterer, l)
+			if !ok {
+				continue
+			}
+			event.name = names[l.Address]
+			event.blockNumber = block.blockNumber
+			events = append(events, event)
+		}
+		return NFTEventsMsg{chainId: chainId, blockNumber: block.blockNumber, events: events}
+	}
+}
+
+// parseNFTEvent decodes l with the binding. ERC-721 Transfer and Approval index
+// the token id, so logs with the ERC-20 layout of three topics are skipped.
+func parseNFTEvent(filterer *erc721.Erc721Filterer, l types.Log) (nftEvent, bool) {
+	if len(l.Topics) == 0 {
+		return nftEvent{}, false
+	}
+	event := nftEvent{collection: l.Address, txHash: l.TxHash}
+
+	switch l.Topics[0] {
+	case transferTopic:
+		if len(l.Topics) != 4 {
+			return nftEvent{}, false
+		}
+		transfer, err := filterer.ParseTransfer(l)
+		if err != nil {
+			return nftEvent{}, false
+		}
+		event.kind = "transfer"
+		event.from, event.to, event.tokenId = transfer.From, transfer.To, transfer.TokenId
+		if transfer.From == (common.Address{}) {
+			event.kind = "mint"
+		} else if transfer.To == (common.Address{}) {
+			event.kind = "burn"
+		}
+
+	case approvalTopic:
+		if len(l.Topics) != 4 {
+			return nftEvent{}, false
+		}
+		approval, err := filterer.ParseApproval(l)
+		if err != nil {
+			return nftEvent{}, false
+		}
+		event.kind = "approval"
+		event.from, event.to, event.tokenId = approval.Owner, approval.Approved, approval.TokenId
+
+	case approvalForAllTopic:
+		approval, err := filterer.ParseApprovalForAll(l)
+		if err != nil {
+			return nftEvent{}, false
+		}
+		event.kind = "approval for all"
+		event.from, event.to, event.approved = approval.Owner, approval.Operator, approval.Approved
+
+	default:
+		return nftEvent{}, false
+	}
+	return event, true
+}
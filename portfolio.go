@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Multicall3, deployed at the same address on most chains. Chains without it
+// can set their own in chains.json.
+var defaultMulticall = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+const multicallJSON = `[{"name":"aggregate3","type":"function","stateMutability":"payable",
+	"inputs":[{"name":"calls","type":"tuple[]","components":[
+		{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}]}],
+	"outputs":[{"name":"returnData","type":"tuple[]","components":[
+		{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}]}]}]`
+
+var multicallABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(multicallJSON))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+var (
+	balanceOfSelector = crypto.Keccak256([]byte("balanceOf(address)"))[:4]
+	symbolSelector    = crypto.Keccak256([]byte("symbol()"))[:4]
+)
+
+// field names match aggregate3's tuples so the abi package can fill them in
+type multicall struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+func (c chain) multicall() common.Address {
+	if c.Multicall == "" {
+		return defaultMulticall
+	}
+	return common.HexToAddress(c.Multicall)
+}
+
+type tokenInfo struct {
+	symbol   string
+	decimals int
+}
+
+type tokenBalance struct {
+	value       *big.Int
+	blockNumber int // block the balance was read at
+}
+
+// holding is a tracked address's balance of a token
+type holding struct {
+	holder, token common.Address
+	balance       tokenBalance
+}
+
+// sent to a session with token balances of tracked addresses read at a block,
+// and details of tokens it didn't know yet
+type PortfolioMsg struct {
+	chainId     string
+	blockNumber int
+	tokens      map[common.Address]tokenInfo
+	holdings    []holding
+	err         error
+}
+
+// fetchPortfolio reads the configured tokens' balances of every tracked
+// address. After the first read a balance is only read again when a Transfer
+// in or out of it shows up in block. Everything goes out as one multicall.
+func (m model) fetchPortfolio(block BlockMsg) tea.Cmd {
+	tokens := m.app.tokenTracking.ERC20[m.chain.Id].Addresses
+	tracked := m.trackingEOA[m.chain.Id]
+	if len(tokens) == 0 || len(tracked.addresses) == 0 || m.chain.Source == "replay" {
+		return nil
+	}
+	chainId := m.chain.Id
+	multicallAddress := m.chain.multicall()
+
+	type pair struct{ holder, token common.Address }
+	needed := make(map[pair]bool)
+	var known bool
+	for _, holder := range tracked.addresses {
+		for _, token := range tokens {
+			if _, ok := tracked.holdings[holder][token]; ok {
+				known = true
+			} else {
+				needed[pair{holder, token}] = true
+			}
+		}
+	}
+	var unknownTokens []common.Address
+	for _, token := range tokens {
+		if _, ok := tracked.tokens[token]; !ok {
+			unknownTokens = append(unknownTokens, token)
+		}
+	}
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		rpc := m.app.chainIdToInfo[chainId].rpc
+
+		// transfers only matter for balances that have already been read
+		if known {
+			logs, err := m.app.blockLogs(ctx, chainId, block, tokens, []common.Hash{transferTopic})
+			if err != nil {
+				return PortfolioMsg{chainId: chainId, blockNumber: block.blockNumber, err: err}
+			}
+			for _, l := range logs {
+				transfer, ok := parseLogTransfer(l)
+				if !ok || l.Removed {
+					continue
+				}
+				for _, holder := range tracked.addresses {
+					if (transfer.From == holder || transfer.To == holder) && containsAddress(tokens, l.Address) {
+						needed[pair{holder, l.Address}] = true
+					}
+				}
+			}
+		}
+		if len(needed) == 0 && len(unknownTokens) == 0 {
+			return nil
+		}
+
+		var calls []multicall
+		for _, token := range unknownTokens {
+			calls = append(calls,
+				multicall{Target: token, AllowFailure: true, CallData: decimalsSelector},
+				multicall{Target: token, AllowFailure: true, CallData: symbolSelector},
+			)
+		}
+		var pairs []pair
+		for p := range needed {
+			pairs = append(pairs, p)
+			calldata := append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(p.holder.Bytes(), 32)...)
+			calls = append(calls, multicall{Target: p.token, AllowFailure: true, CallData: calldata})
+		}
+
+		results, err := aggregate(ctx, rpc, multicallAddress, calls, block.blockNumber)
+		if err != nil {
+			return PortfolioMsg{chainId: chainId, blockNumber: block.blockNumber, err: err}
+		}
+
+		msg := PortfolioMsg{chainId: chainId, blockNumber: block.blockNumber, tokens: make(map[common.Address]tokenInfo)}
+		for i, token := range unknownTokens {
+			info := tokenInfo{symbol: shortAddress(token), decimals: defaultDecimals}
+			if decimals := results[2*i]; decimals.Success && len(decimals.ReturnData) >= 32 {
+				info.decimals = int(new(big.Int).SetBytes(decimals.ReturnData[:32]).Int64())
+			}
+			if symbol := results[2*i+1]; symbol.Success {
+				if decoded, ok := decodeSymbol(symbol.ReturnData); ok {
+					info.symbol = decoded
+				}
+			}
+			msg.tokens[token] = info
+		}
+		for i, p := range pairs {
+			result := results[2*len(unknownTokens)+i]
+			if !result.Success || len(result.ReturnData) < 32 {
+				continue
+			}
+			msg.holdings = append(msg.holdings, holding{
+				holder:  p.holder,
+				token:   p.token,
+				balance: tokenBalance{value: new(big.Int).SetBytes(result.ReturnData[:32]), blockNumber: block.blockNumber},
+			})
+		}
+		return msg
+	}
+}
+
+// aggregate makes calls through the multicall contract at blockNumber, calls
+// that revert come back unsuccessful rather than failing the lot.
+func aggregate(ctx context.Context, rpc *rpcBatcher, multicallAddress common.Address, calls []multicall, blockNumber int) ([]multicallResult, error) {
+	input, err := multicallABI.Pack("aggregate3", calls)
+	if err != nil {
+		return nil, err
+	}
+	var output hexutil.Bytes
+	call := map[string]interface{}{"to": multicallAddress, "data": hexutil.Bytes(input)}
+	if err := rpc.Call(ctx, &output, "eth_call", call, hexutil.EncodeUint64(uint64(blockNumber))); err != nil {
+		return nil, err
+	}
+	if len(output) == 0 {
+		return nil, fmt.Errorf("no multicall contract at %s", multicallAddress)
+	}
+	unpacked, err := multicallABI.Unpack("aggregate3", output)
+	if err != nil {
+		return nil, err
+	}
+	results := *abi.ConvertType(unpacked[0], new([]multicallResult)).(*[]multicallResult)
+	if len(results) != len(calls) {
+		return nil, fmt.Errorf("multicall returned %d results for %d calls", len(results), len(calls))
+	}
+	return results, nil
+}
+
+// decodeSymbol reads symbol() as a string, or as bytes32 like some older
+// tokens return it.
+func decodeSymbol(data []byte) (string, bool) {
+	stringType, _ := abi.NewType("string", "", nil)
+	if values, err := (abi.Arguments{{Type: stringType}}).Unpack(data); err == nil {
+		symbol, _ := values[0].(string)
+		return symbol, symbol != ""
+	}
+	if len(data) == 32 {
+		symbol := string(bytes.TrimRight(data, "\x00"))
+		return symbol, symbol != ""
+	}
+	return "", false
+}
+
+func containsAddress(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *model) savePortfolio(msg PortfolioMsg) {
+	eoaTracking := m.trackingEOA[msg.chainId]
+	if eoaTracking.tokens == nil {
+		eoaTracking.tokens = make(map[common.Address]tokenInfo)
+	}
+	if eoaTracking.holdings == nil {
+		eoaTracking.holdings = make(map[common.Address]map[common.Address]tokenBalance)
+	}
+	for token, info := range msg.tokens {
+		eoaTracking.tokens[token] = info
+	}
+	for _, h := range msg.holdings {
+		if eoaTracking.holdings[h.holder] == nil {
+			eoaTracking.holdings[h.holder] = make(map[common.Address]tokenBalance)
+		}
+		// reads can come back out of order
+		if previous, ok := eoaTracking.holdings[h.holder][h.token]; ok && previous.blockNumber > h.balance.blockNumber {
+			continue
+		}
+		eoaTracking.holdings[h.holder][h.token] = h.balance
+	}
+	m.trackingEOA[msg.chainId] = eoaTracking
+}
+
+// rollbackPortfolio forgets balances read at blocks that were reorged out so
+// they're read again with the next block.
+func (m *model) rollbackPortfolio(from int) {
+	for _, balances := range m.trackingEOA[m.chain.Id].holdings {
+		for token, balance := range balances {
+			if balance.blockNumber >= from {
+				delete(balances, token)
+			}
+		}
+	}
+}
+
+// renderPortfolio is a small table per tracked address of its balance of each
+// configured token.
+func (m *model) renderPortfolio() []string {
+	tokens := m.app.tokenTracking.ERC20[m.chain.Id].Addresses
+	tracked := m.trackingEOA[m.chain.Id]
+	if len(tokens) == 0 {
+		return []string{fmt.Sprintf("no erc20 tokens configured for chain %s in config/tokenTracking.json", m.chain.Id)}
+	}
+	if len(tracked.addresses) == 0 {
+		return []string{"no EOA addresses tracked, add some in set up or from a profile"}
+	}
+
+	var lines []string
+	for i, holder := range tracked.addresses {
+		lines = append(lines, fmt.Sprintf("%s (%s)", tracked.names[i], shortAddress(holder)))
+		for _, token := range tokens {
+			info, ok := tracked.tokens[token]
+			if !ok {
+				info = tokenInfo{symbol: shortAddress(token), decimals: defaultDecimals}
+			}
+			amount, updated := "…", ""
+			if balance, ok := tracked.holdings[holder][token]; ok {
+				amount = ToDecimal(balance.value, info.decimals).Truncate(4).String()
+				updated = fmt.Sprint("#", balance.blockNumber)
+			}
+			lines = append(lines, fmt.Sprintf("  %-12s %24s   %s", truncate(info.symbol, 12), amount, updated))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type profilePage struct {
+	list     list.Model
+	name     textinput.Model
+	renaming bool
+}
+
+// kind is the tracking list the profile's accounts go into.
+func (p profile) kind() string {
+	if p.Kind == "erc721" {
+		return "erc721"
+	}
+	return "eoa"
+}
+
+// inUse reports whether tracked is exactly the profile's accounts.
+func (p profile) inUse(tracked tracking) bool {
+	if len(p.Accounts) != len(tracked.addresses) {
+		return false
+	}
+	for i, account := range p.Accounts {
+		if account.Address != tracked.addresses[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// trackingFor is the current chain's tracking list a profile of kind replaces.
+func (m *model) trackingFor(kind string) map[string]tracking {
+	if kind == "erc721" {
+		return m.trackingERC721
+	}
+	return m.trackingEOA
+}
+
+func (m *model) openProfiles() {
+	m.profilePage.list = intializeTrackingProfileList(m.profiles)
+	m.profilePage.name = textinput.New()
+	m.profilePage.name.Placeholder = "profile name"
+	m.profilePage.name.CharLimit = 30
+	m.profilePage.renaming = false
+	m.refreshProfiles()
+}
+
+// refreshProfiles redraws the list, marking the profiles the chain is using.
+func (m *model) refreshProfiles() {
+	items := make([]list.Item, len(m.profiles))
+	for i, p := range m.profiles {
+		profile := profileItem(p)
+		if p.inUse(m.trackingFor(p.kind())[m.chain.Id]) {
+			profile.description += " ✓"
+		}
+		items[i] = profile
+	}
+	m.profilePage.list.SetItems(items)
+}
+
+// applyProfile swaps the current chain's tracked eoas or collections for the
+// selected profile's accounts. Activity already seen is kept.
+func (m *model) applyProfile() {
+	i := m.profilePage.list.Index()
+	if i >= len(m.profiles) {
+		return
+	}
+	p := m.profiles[i]
+	trackingMap := m.trackingFor(p.kind())
+
+	tracked := trackingMap[m.chain.Id]
+	applied := fromTrackedAddresses(p.Accounts)
+	tracked.names, tracked.addresses = applied.names, applied.addresses
+	trackingMap[m.chain.Id] = tracked
+
+	m.saveSettings()
+}
+
+// newProfile saves what the chain tracks for kind as a profile and asks for a
+// name for it.
+func (m *model) newProfile(kind string) tea.Cmd {
+	name := fmt.Sprint("profile ", len(m.profiles)+1)
+	m.profiles = append(m.profiles, profile{
+		Name:     name,
+		Kind:     kind,
+		Accounts: toTrackedAddresses(m.trackingFor(kind)[m.chain.Id]),
+	})
+	m.saveSettings()
+	m.refreshProfiles()
+	m.profilePage.list.Select(len(m.profiles) - 1)
+	return m.startRename()
+}
+
+func (m *model) startRename() tea.Cmd {
+	if len(m.profiles) == 0 {
+		return nil
+	}
+	m.profilePage.renaming = true
+	m.profilePage.name.SetValue(m.profiles[m.profilePage.list.Index()].Name)
+	m.profilePage.name.CursorEnd()
+	return m.profilePage.name.Focus()
+}
+
+// updateRename takes every key while a name is being typed, so they don't
+// trigger the app's shortcuts.
+func (m *model) updateRename(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "enter":
+		name := strings.TrimSpace(m.profilePage.name.Value())
+		if name == "" {
+			return nil
+		}
+		m.profiles[m.profilePage.list.Index()].Name = name
+		m.saveSettings()
+		m.refreshProfiles()
+		fallthrough
+	case "esc":
+		m.profilePage.renaming = false
+		m.profilePage.name.Blur()
+		return nil
+	case "ctrl+c":
+		m.app.disconnectClient(m.chain, m.client.id)
+		return tea.Quit
+	}
+
+	var cmd tea.Cmd
+	m.profilePage.name, cmd = m.profilePage.name.Update(msg)
+	return cmd
+}
+
+func (m *model) renderProfiles() string {
+	title := m.styles.center.Render("Tracking Profiles")
+	height := m.height - 4
+
+	profiles := m.renderer.NewStyle().Width(40).Height(height).Border(lipgloss.NormalBorder()).Render(m.profilePage.list.View())
+
+	var accounts []string
+	if i := m.profilePage.list.Index(); i < len(m.profiles) {
+		p := m.profiles[i]
+		accounts = append(accounts, fmt.Sprintf("%s (%s) on %s:", p.Name, p.kind(), m.chain.Name), "")
+		for _, account := range p.Accounts {
+			accounts = append(accounts, fmt.Sprintf("%-20s %s", truncate(account.Name, 20), account.Address.Hex()))
+		}
+	}
+	if m.profilePage.renaming {
+		accounts = append(accounts, "", "rename:", m.profilePage.name.View())
+	}
+	details := m.renderer.NewStyle().Width(66).Height(height).Border(lipgloss.NormalBorder()).Render(lipgloss.JoinVertical(lipgloss.Left, accounts...))
+
+	return lipgloss.JoinVertical(lipgloss.Center, title, lipgloss.JoinHorizontal(lipgloss.Top, profiles, details))
+}
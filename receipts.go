@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type Receipt struct {
+	status            uint64 // 1 success, 0 reverted
+	gasUsed           uint64
+	effectiveGasPrice *big.Int
+	logs              []types.Log
+}
+
+type rpcReceipt struct {
+	TransactionHash   common.Hash    `json:"transactionHash"`
+	Status            hexutil.Uint64 `json:"status"`
+	GasUsed           hexutil.Uint64 `json:"gasUsed"`
+	EffectiveGasPrice *hexutil.Big   `json:"effectiveGasPrice"`
+	Logs              []types.Log    `json:"logs"`
+}
+
+func (r rpcReceipt) decode() *Receipt {
+	return &Receipt{
+		status:            uint64(r.Status),
+		gasUsed:           uint64(r.GasUsed),
+		effectiveGasPrice: bigOrZero(r.EffectiveGasPrice),
+		logs:              r.Logs,
+	}
+}
+
+// failed is true for transactions whose receipt says they reverted, and false
+// while the receipt is unknown.
+func (tx Transaction) failed() bool {
+	return tx.receipt != nil && tx.receipt.status == 0
+}
+
+// attachReceipts fetches receipts for every transaction in the block when the
+// chain has them turned on. It asks for the whole block with
+// eth_getBlockReceipts and falls back to a batch of eth_getTransactionReceipt
+// calls for endpoints that don't have it.
+func (a *app) attachReceipts(ctx context.Context, source BlockSource, chainId string, block *BlockMsg) {
+	info := a.chainIdToInfo[chainId]
+	if !info.chain.Receipts || len(block.transactions) == 0 {
+		return
+	}
+
+	var receipts []rpcReceipt
+	if !info.noBlockReceipts {
+		err := source.Call(ctx, &receipts, "eth_getBlockReceipts", hexutil.EncodeUint64(uint64(block.blockNumber)))
+		if err != nil && isMethodNotFound(err) {
+			log.Printf("eth_getBlockReceipts not available on %s, fetching receipts one by one", chainId)
+			info.noBlockReceipts = true
+		} else if err != nil {
+			log.Println("error fetching block receipts", err)
+			return
+		}
+	}
+
+	if info.noBlockReceipts {
+		fetched := make([]*rpcReceipt, len(block.transactions))
+		calls := make([]rpc.BatchElem, len(block.transactions))
+		for i, tx := range block.transactions {
+			calls[i] = rpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []interface{}{tx.hash}, Result: &fetched[i]}
+		}
+		// providers cap how big a batch can be, so big blocks go out in
+		// chunks and a chunk that fails doesn't lose the others
+		for start := 0; start < len(calls); start += maxBatchSize {
+			batchReceipts(ctx, source, calls[start:min(start+maxBatchSize, len(calls))])
+		}
+		for i, call := range calls {
+			if call.Error != nil {
+				log.Println("error fetching transaction receipt", call.Error)
+				continue
+			}
+			if fetched[i] != nil {
+				receipts = append(receipts, *fetched[i])
+			}
+		}
+	}
+
+	byHash := make(map[common.Hash]rpcReceipt, len(receipts))
+	for _, receipt := range receipts {
+		byHash[receipt.TransactionHash] = receipt
+	}
+	for i, tx := range block.transactions {
+		if receipt, ok := byHash[tx.hash]; ok {
+			block.transactions[i].receipt = receipt.decode()
+		}
+	}
+}
+
+// how many times a chunk of receipt calls is sent before giving up on it
+const receiptAttempts = 3
+
+// batchReceipts sends calls as one batch, sending the ones that failed again
+// up to receiptAttempts times. Calls still failing are left with their error.
+func batchReceipts(ctx context.Context, source BlockSource, calls []rpc.BatchElem) {
+	pending := make([]int, len(calls))
+	for i := range calls {
+		pending[i] = i
+	}
+	for attempt := 0; attempt < receiptAttempts && len(pending) > 0 && ctx.Err() == nil; attempt++ {
+		batch := make([]rpc.BatchElem, len(pending))
+		for i, index := range pending {
+			batch[i] = calls[index]
+			batch[i].Error = nil
+		}
+		err := source.BatchCall(ctx, batch)
+		if err != nil {
+			log.Println("error fetching transaction receipts", err)
+		}
+
+		var failed []int
+		for i, index := range pending {
+			calls[index].Error = batch[i].Error
+			if err != nil {
+				calls[index].Error = err
+			}
+			if calls[index].Error != nil {
+				failed = append(failed, index)
+			}
+		}
+		pending = failed
+	}
+}
+
+// JSON-RPC error codes for a method the node doesn't have
+const (
+	methodNotFoundCode     = -32601
+	methodNotSupportedCode = -32004
+)
+
+// isMethodNotFound only goes by the error code, messages like "header not
+// found" come back for fresh heads on nodes that do have the method.
+func isMethodNotFound(err error) bool {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	return rpcErr.ErrorCode() == methodNotFoundCode || rpcErr.ErrorCode() == methodNotSupportedCode
+}
+
+// methodNotFoundError is what the simulated and replay sources answer methods
+// they don't know with, the same as a node would.
+type methodNotFoundError struct {
+	method string
+}
+
+func (e methodNotFoundError) Error() string {
+	return fmt.Sprintf("the method %s does not exist/is not available", e.method)
+}
+
+func (e methodNotFoundError) ErrorCode() int { return methodNotFoundCode }
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// where recordings are written, every file in here shows up as a replay chain
+const recordingsDir = "recordings"
+
+// recordedBlock is one line of a recording: the BlockMsg sent to clients and
+// the eth_getBlockByNumber response it was decoded from.
+type recordedBlock struct {
+	Number       int             `json:"number"`
+	Hash         common.Hash     `json:"hash"`
+	ParentHash   common.Hash     `json:"parentHash"`
+	Timestamp    int64           `json:"timestamp"`
+	Transactions int             `json:"transactions"`
+	TotalValue   string          `json:"totalValue"`
+	Backfilled   bool            `json:"backfilled"`
+	ReceivedAt   int64           `json:"receivedAt"` // unix millis
+	Raw          json.RawMessage `json:"raw"`
+}
+
+// recorder appends every block a listener sends to a newline delimited file.
+type recorder struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newRecorder(chain chain) (*recorder, error) {
+	if err := os.MkdirAll(recordingsDir, 0o755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s-%s.ndjson", chain.Id, time.Now().Format("20060102-150405"))
+	file, err := os.Create(filepath.Join(recordingsDir, name))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("recording %s to %s", chain.Name, file.Name())
+	return &recorder{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (r *recorder) record(block BlockMsg, raw json.RawMessage) {
+	err := r.encoder.Encode(recordedBlock{
+		Number:       block.blockNumber,
+		Hash:         block.hash,
+		ParentHash:   block.parentHash,
+		Timestamp:    block.timestamp.Int64(),
+		Transactions: len(block.transactions),
+		TotalValue:   block.totalValue.String(),
+		Backfilled:   block.backfilled,
+		ReceivedAt:   time.Now().UnixMilli(),
+		Raw:          raw,
+	})
+	if err != nil {
+		log.Println("error writing recording", err)
+	}
+}
+
+func (r *recorder) close() {
+	r.file.Close()
+}
+
+// replayChains lists a chain for every recording on disk that isn't already
+// configured in chains.json.
+func replayChains(configured []chain) []chain {
+	files, _ := filepath.Glob(filepath.Join(recordingsDir, "*.ndjson"))
+
+	var chains []chain
+	for _, file := range files {
+		known := false
+		for _, chain := range configured {
+			known = known || (chain.Source == "replay" && filepath.Clean(chain.Replay) == file)
+		}
+		if known {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".ndjson")
+		chains = append(chains, chain{
+			Name:           fmt.Sprint("Replay ", name),
+			Id:             fmt.Sprint("replay:", name),
+			Source:         "replay",
+			Replay:         file,
+			NativeCurrency: "Eth",
+		})
+	}
+	return chains
+}
+
+// replaySource plays a recording back as if it came from a node, at the pace it
+// was recorded divided by the chain's replay speed. Once the recording runs out
+// it keeps the last head.
+type replaySource struct {
+	chain    chain
+	endpoint *endpoint
+	speed    float64
+	blocks   []recordedBlock
+
+	mu       sync.Mutex
+	next     int // index of the next record to play
+	head     int
+	byNumber map[int]json.RawMessage
+}
+
+func newReplaySource(chain chain, endpoint *endpoint) (*replaySource, error) {
+	file, err := os.Open(chain.Replay)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := &replaySource{
+		chain:    chain,
+		endpoint: endpoint,
+		speed:    chain.ReplaySpeed,
+		byNumber: make(map[int]json.RawMessage),
+	}
+	if r.speed <= 0 {
+		r.speed = 1
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		var block recordedBlock
+		if err := json.Unmarshal(scanner.Bytes(), &block); err != nil {
+			return nil, fmt.Errorf("bad recording %s: %w", chain.Replay, err)
+		}
+		r.blocks = append(r.blocks, block)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(r.blocks) == 0 {
+		return nil, fmt.Errorf("recording %s is empty", chain.Replay)
+	}
+
+	// the first head is what the listener starts from
+	r.advance()
+	return r, nil
+}
+
+// advance plays records up to and including the next head, backfilled blocks
+// are only made available so the listener can fetch them again. It returns
+// false when the recording is over.
+func (r *replaySource) advance() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for r.next < len(r.blocks) {
+		block := r.blocks[r.next]
+		r.next++
+		r.byNumber[block.Number] = block.Raw
+		if !block.Backfilled {
+			r.head = block.Number
+			return true
+		}
+	}
+	return false
+}
+
+// wait is how long to pause before playing the record at next.
+func (r *replaySource) wait() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next == 0 || r.next >= len(r.blocks) {
+		return 0
+	}
+	elapsed := time.Duration(r.blocks[r.next].ReceivedAt-r.blocks[r.next-1].ReceivedAt) * time.Millisecond
+	return time.Duration(float64(elapsed) / r.speed)
+}
+
+func (r *replaySource) Heads(ctx context.Context, headers chan<- *types.Header) (ethereum.Subscription, error) {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		for {
+			select {
+			case <-time.After(r.wait()):
+			case <-quit:
+				return nil
+			}
+			if !r.advance() {
+				log.Printf("replay of %s finished", r.chain.Replay)
+				<-quit
+				return nil
+			}
+
+			r.mu.Lock()
+			head := r.head
+			r.mu.Unlock()
+			select {
+			case headers <- &types.Header{Number: big.NewInt(int64(head))}:
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+func (r *replaySource) Call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	start := time.Now()
+	response, err := r.respond(method, args)
+	if err == nil {
+		err = json.Unmarshal(response, result)
+	}
+	r.endpoint.observe(time.Since(start), err)
+	return err
+}
+
+func (r *replaySource) BatchCall(ctx context.Context, calls []rpc.BatchElem) error {
+	return batchEach(ctx, r, calls)
+}
+
+func (r *replaySource) respond(method string, args []interface{}) (json.RawMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch method {
+	case "eth_blockNumber":
+		return json.Marshal(hexutil.Uint64(r.head))
+
+	case "eth_getBlockByNumber":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("missing value for required argument")
+		}
+		tag, _ := args[0].(string)
+		number, err := hexutil.DecodeUint64(tag)
+		if err != nil {
+			number = uint64(r.head)
+		}
+		raw, ok := r.byNumber[int(number)]
+		if !ok {
+			return json.RawMessage("null"), nil
+		}
+		return raw, nil
+
+	case "eth_getLogs":
+		// logs aren't recorded
+		return json.RawMessage("[]"), nil
+	}
+
+	return nil, methodNotFoundError{method}
+}
+
+func (r *replaySource) Status() EndpointMsg {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := r.endpoint.status()
+	status.url = fmt.Sprintf("%s %d/%d (x%g)", r.chain.Replay, r.next, len(r.blocks), r.speed)
+	status.total = 1
+	return status
+}
+
+func (r *replaySource) Close() {}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// every *.abi and *.json file in here is read into the registry
+const abiDir = "contracts/build"
+
+// function signatures that are decoded even without an abi for the contract
+//
+//go:embed contracts/selectors.txt
+var commonSelectors string
+
+// abiRegistry indexes the events of every ABI in a directory by topic0 so logs
+// can be decoded without a binding, and their functions by selector along with
+// the bundled common signatures so calldata can be. Files dropped into the
+// directory are picked up the next time a log is decoded.
+type abiRegistry struct {
+	dir     string
+	mu      sync.Mutex
+	loaded  string                      // names and modification times of the files when last read
+	events  map[common.Hash][]abi.Event // events sharing a topic0 differ in what's indexed
+	methods map[[4]byte][]abi.Method    // a selector can clash between signatures
+	sources map[string]string           // event layout or method signature to the file it came from
+}
+
+func newABIRegistry(dir string) *abiRegistry {
+	r := &abiRegistry{dir: dir}
+	r.refresh()
+	return r
+}
+
+// refresh reads the directory again if a file was added, removed or changed.
+// It goes by each file's modification time since editing a file in place
+// doesn't touch the directory's.
+func (r *abiRegistry) refresh() {
+	files, _ := filepath.Glob(filepath.Join(r.dir, "*.abi"))
+	jsonFiles, _ := filepath.Glob(filepath.Join(r.dir, "*.json"))
+	files = append(files, jsonFiles...)
+	var state strings.Builder
+	for _, file := range files {
+		if stat, err := os.Stat(file); err == nil {
+			fmt.Fprintln(&state, file, stat.ModTime().UnixNano(), stat.Size())
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.events != nil && state.String() == r.loaded {
+		return
+	}
+
+	r.loaded = state.String()
+	r.events = make(map[common.Hash][]abi.Event)
+	r.methods = make(map[[4]byte][]abi.Method)
+	r.sources = make(map[string]string)
+
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			log.Printf("error reading abi %s: %v", file, err)
+			continue
+		}
+		parsed, err := abi.JSON(strings.NewReader(string(raw)))
+		if err != nil {
+			log.Printf("error parsing abi %s: %v", file, err)
+			continue
+		}
+		for _, event := range parsed.Events {
+			r.add(event, file)
+		}
+		for _, method := range parsed.Methods {
+			r.addMethod(method, file)
+		}
+	}
+
+	// signatures from the abis come first since they name their arguments
+	for _, line := range strings.Split(commonSelectors, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		method, err := parseSignature(line)
+		if err != nil {
+			log.Printf("bad signature %s: %v", line, err)
+			continue
+		}
+		r.addMethod(method, "selectors.txt")
+	}
+	log.Printf("abi registry has %d event topics and %d selectors", len(r.events), len(r.methods))
+}
+
+func (r *abiRegistry) addMethod(method abi.Method, file string) {
+	if _, ok := r.sources[method.Sig]; ok {
+		return
+	}
+	r.sources[method.Sig] = file
+	selector := [4]byte(method.ID)
+	r.methods[selector] = append(r.methods[selector], method)
+}
+
+// parseSignature turns a signature like transfer(address,uint256) into a method
+// with arguments named arg0, arg1 and so on.
+func parseSignature(signature string) (abi.Method, error) {
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return abi.Method{}, fmt.Errorf("not a function signature")
+	}
+	name := signature[:open]
+
+	var inputs abi.Arguments
+	for i, arg := range splitTypes(signature[open+1 : len(signature)-1]) {
+		marshaling := typeMarshaling(arg, fmt.Sprint("arg", i))
+		typ, err := abi.NewType(marshaling.Type, "", marshaling.Components)
+		if err != nil {
+			return abi.Method{}, err
+		}
+		inputs = append(inputs, abi.Argument{Name: marshaling.Name, Type: typ})
+	}
+	return abi.NewMethod(name, name, abi.Function, "", false, false, inputs, nil), nil
+}
+
+// splitTypes splits a comma separated type list, leaving tuples whole.
+func splitTypes(list string) []string {
+	var (
+		parts []string
+		depth int
+		start int
+	)
+	for i, c := range list {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if list != "" {
+		parts = append(parts, list[start:])
+	}
+	return parts
+}
+
+// typeMarshaling describes a type from a signature the way an abi file would,
+// tuples get their fields named field0, field1 and so on.
+func typeMarshaling(typ, name string) abi.ArgumentMarshaling {
+	if !strings.HasPrefix(typ, "(") {
+		return abi.ArgumentMarshaling{Name: name, Type: typ}
+	}
+	end := strings.LastIndex(typ, ")")
+	marshaling := abi.ArgumentMarshaling{Name: name, Type: "tuple" + typ[end+1:]}
+	for i, component := range splitTypes(typ[1:end]) {
+		marshaling.Components = append(marshaling.Components, typeMarshaling(component, fmt.Sprint("field", i)))
+	}
+	return marshaling
+}
+
+// decodedCall is calldata with its function's signature and arguments.
+type decodedCall struct {
+	signature string
+	fields    []decodedField
+}
+
+// method names what tx does: the signature of the function it calls when it's
+// known, otherwise the bare selector.
+func (r *abiRegistry) method(tx Transaction) string {
+	switch {
+	case tx.to == nil:
+		return "contract creation"
+	case len(tx.input) == 0:
+		return "native transfer"
+	case len(tx.input) < 4:
+		return fmt.Sprintf("0x%x", tx.input)
+	}
+	if call, ok := r.decodeCall(tx.input); ok {
+		return call.signature
+	}
+	return fmt.Sprintf("0x%x", tx.input[:4])
+}
+
+// decodeCall looks up the function input calls by its selector, ok is false
+// when no known signature fits.
+func (r *abiRegistry) decodeCall(input []byte) (decodedCall, bool) {
+	if len(input) < 4 {
+		return decodedCall{}, false
+	}
+	r.mu.Lock()
+	candidates := r.methods[[4]byte(input[:4])]
+	r.mu.Unlock()
+
+	for _, method := range candidates {
+		values, err := method.Inputs.Unpack(input[4:])
+		if err != nil {
+			continue
+		}
+		decoded := decodedCall{signature: method.Sig}
+		for i, input := range method.Inputs {
+			name := strings.TrimPrefix(input.Name, "_")
+			if name == "" {
+				name = fmt.Sprint("arg", i)
+			}
+			decoded.fields = append(decoded.fields, decodedField{name: name, value: formatABIValue(values[i], false)})
+		}
+		return decoded, true
+	}
+	return decodedCall{}, false
+}
+
+func (r *abiRegistry) add(event abi.Event, file string) {
+	if event.Anonymous {
+		return
+	}
+	layout := eventLayout(event)
+	if _, ok := r.sources[layout]; ok {
+		return
+	}
+	r.sources[layout] = file
+	r.events[event.ID] = append(r.events[event.ID], event)
+}
+
+// eventLayout is the event's signature with indexed arguments marked, Transfer
+// of ERC-20 and ERC-721 only differ there.
+func eventLayout(event abi.Event) string {
+	args := make([]string, len(event.Inputs))
+	for i, input := range event.Inputs {
+		args[i] = input.Type.String()
+		if input.Indexed {
+			args[i] += " indexed"
+		}
+	}
+	return fmt.Sprintf("%s(%s)", event.RawName, strings.Join(args, ","))
+}
+
+type decodedField struct {
+	name, value string
+}
+
+// decodedLog is a log with its event's name and arguments, in the order the
+// event declares them.
+type decodedLog struct {
+	event  string
+	fields []decodedField
+}
+
+func (d decodedLog) String() string {
+	fields := make([]string, len(d.fields))
+	for i, field := range d.fields {
+		fields[i] = fmt.Sprint(field.name, "=", field.value)
+	}
+	return fmt.Sprintf("%s(%s)", d.event, strings.Join(fields, ", "))
+}
+
+// decode looks up l's event by topic0, ok is false when no ABI in the registry
+// has an event that fits.
+func (r *abiRegistry) decode(l types.Log) (decodedLog, bool) {
+	if len(l.Topics) == 0 {
+		return decodedLog{}, false
+	}
+	r.mu.Lock()
+	candidates := r.events[l.Topics[0]]
+	r.mu.Unlock()
+
+	for _, event := range candidates {
+		var indexed abi.Arguments
+		for _, input := range event.Inputs {
+			if input.Indexed {
+				indexed = append(indexed, input)
+			}
+		}
+		if len(indexed) != len(l.Topics)-1 {
+			continue
+		}
+
+		// by position rather than by name, unnamed arguments would all
+		// land on the same key
+		topics, ok := parseIndexed(indexed, l.Topics[1:])
+		if !ok {
+			continue
+		}
+		data, err := event.Inputs.NonIndexed().Unpack(l.Data)
+		if err != nil {
+			continue
+		}
+
+		decoded := decodedLog{event: event.RawName}
+		for i, input := range event.Inputs {
+			name := strings.TrimPrefix(input.Name, "_")
+			if name == "" {
+				name = fmt.Sprint("arg", i)
+			}
+			var value interface{}
+			if input.Indexed {
+				value, topics = topics[0], topics[1:]
+			} else {
+				value, data = data[0], data[1:]
+			}
+			decoded.fields = append(decoded.fields, decodedField{name: name, value: formatABIValue(value, true)})
+		}
+		return decoded, true
+	}
+	return decodedLog{}, false
+}
+
+// parseIndexed decodes each topic as its indexed argument, in order. Dynamic
+// types only have their hash in the topic so they come back as that.
+func parseIndexed(indexed abi.Arguments, topics []common.Hash) ([]interface{}, bool) {
+	values := make([]interface{}, len(indexed))
+	for i, input := range indexed {
+		input.Name = "value"
+		parsed := make(map[string]interface{})
+		if err := abi.ParseTopicsIntoMap(parsed, abi.Arguments{input}, topics[i:i+1]); err != nil {
+			return nil, false
+		}
+		values[i] = parsed["value"]
+	}
+	return values, true
+}
+
+// formatABIValue prints a decoded argument, short abbreviates addresses and
+// hashes to fit a line.
+func formatABIValue(value interface{}, short bool) string {
+	switch v := value.(type) {
+	case common.Address:
+		if !short {
+			return v.Hex()
+		}
+		return shortAddress(v)
+	case common.Hash:
+		if !short {
+			return v.Hex()
+		}
+		return v.TerminalString()
+	case *big.Int:
+		return v.String()
+	case []byte:
+		return fmt.Sprintf("0x%x", v)
+	case [32]byte:
+		return formatABIValue(common.Hash(v), short)
+	}
+	return fmt.Sprint(value)
+}
+
+// contractEvent is a log of a tracked contract, decoded when the registry knows
+// its event.
+type contractEvent struct {
+	name        string
+	contract    common.Address
+	decoded     decodedLog
+	known       bool
+	topic0      common.Hash
+	txHash      common.Hash
+	blockNumber int
+}
+
+func (e contractEvent) String() string {
+	if !e.known {
+		return fmt.Sprintf("#%d %-12.12s unknown event %s", e.blockNumber, e.name, e.topic0.TerminalString())
+	}
+	return fmt.Sprintf("#%d %-12.12s %s", e.blockNumber, e.name, e.decoded)
+}
+
+// sent to a session with the logs of its tracked contracts found in a block
+type ContractEventsMsg struct {
+	chainId     string
+	blockNumber int
+	events      []contractEvent
+	err         error
+}
+
+// fetchContractEvents gets every log the tracked contracts emitted in block and
+// decodes them with the abi registry.
+func (m model) fetchContractEvents(block BlockMsg) tea.Cmd {
+	tracked := m.trackingContracts[m.chain.Id]
+	if len(tracked.addresses) == 0 {
+		return nil
+	}
+	chainId := m.chain.Id
+	names := make(map[common.Address]string, len(tracked.addresses))
+	for i, address := range tracked.addresses {
+		names[address] = tracked.names[i]
+	}
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		logs, err := m.app.blockLogs(ctx, chainId, block, tracked.addresses, nil)
+		if err != nil {
+			return ContractEventsMsg{chainId: chainId, blockNumber: block.blockNumber, err: err}
+		}
+
+		m.app.abis.refresh()
+		var events []contractEvent
+		for _, l := range logs {
+			name, ok := names[l.Address]
+			if !ok || l.Removed || len(l.Topics) == 0 {
+				continue
+			}
+			event := contractEvent{name: name, contract: l.Address, topic0: l.Topics[0], txHash: l.TxHash, blockNumber: block.blockNumber}
+			event.decoded, event.known = m.app.abis.decode(l)
+			events = append(events, event)
+		}
+		return ContractEventsMsg{chainId: chainId, blockNumber: block.blockNumber, events: events}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/ethereum/go-ethereum/common"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// where each user's settings are saved, one file per ssh key
+const settingsDir = "settings"
+
+// userSettings is what a user set up, by chain id. It's saved whenever they
+// leave the set up page and loaded when they connect with the same key.
+type userSettings struct {
+	Fingerprint string                   `json:"fingerprint"`
+	Chains      map[string]chainSettings `json:"chains"`
+	Profiles    []profile                `json:"profiles"` // replaces config/profiles.json when set
+}
+
+type chainSettings struct {
+	Memory    int              `json:"memory"`
+	EOA       []trackedAddress `json:"eoa"`
+	ERC20     []trackedAddress `json:"erc20"`
+	ERC721    []trackedAddress `json:"erc721"`
+	Contracts []trackedAddress `json:"contracts"`
+}
+
+type trackedAddress struct {
+	Name    string         `json:"name"`
+	Address common.Address `json:"address"`
+}
+
+// settingsStore reads and writes settings files, writes are serialised since a
+// user can have several sessions open.
+type settingsStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// set on sessions that got in through keyboard-interactive auth
+var keyboardInteractiveKey = &struct{ name string }{"keyboard-interactive"}
+
+// fingerprint identifies a session's user by their public key, empty for
+// sessions that didn't authenticate with one. The public key callback accepts
+// any key a client offers, including ones it can't sign for, so the key is only
+// trusted when keyboard-interactive wasn't what let the session in.
+func fingerprint(s ssh.Session) string {
+	if s.PublicKey() == nil {
+		return ""
+	}
+	if interactive, _ := s.Context().Value(keyboardInteractiveKey).(bool); interactive {
+		return ""
+	}
+	return gossh.FingerprintSHA256(s.PublicKey())
+}
+
+func (s *settingsStore) path(fingerprint string) string {
+	// fingerprints have slashes in them
+	sum := sha256.Sum256([]byte(fingerprint))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// load returns the user's saved settings, ok is false if they have none.
+func (s *settingsStore) load(fingerprint string) (userSettings, bool) {
+	if fingerprint == "" {
+		return userSettings{}, false
+	}
+	raw, err := os.ReadFile(s.path(fingerprint))
+	if errors.Is(err, os.ErrNotExist) {
+		return userSettings{}, false
+	}
+	if err != nil {
+		log.Println("error reading settings", err)
+		return userSettings{}, false
+	}
+
+	var settings userSettings
+	if err := json.Unmarshal(raw, &settings); err != nil {
+		log.Println("error decoding settings", err)
+		return userSettings{}, false
+	}
+	return settings, true
+}
+
+// update loads the user's settings, lets change edit them and writes them
+// back, all under the lock so sessions saving at once don't undo each other.
+func (s *settingsStore) update(fingerprint string, change func(*userSettings)) error {
+	if fingerprint == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings, ok := s.load(fingerprint)
+	if !ok {
+		settings = userSettings{Fingerprint: fingerprint}
+	}
+	change(&settings)
+	return s.save(settings)
+}
+
+// save replaces the user's settings file, callers hold mu.
+func (s *settingsStore) save(settings userSettings) error {
+	raw, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	// written next to the old file and renamed over it so a crash can't leave
+	// half a file behind
+	path := s.path(settings.Fingerprint)
+	if err := os.WriteFile(path+".tmp", raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func toTrackedAddresses(tracked tracking) []trackedAddress {
+	addresses := make([]trackedAddress, len(tracked.addresses))
+	for i, address := range tracked.addresses {
+		addresses[i] = trackedAddress{Name: tracked.names[i], Address: address}
+	}
+	return addresses
+}
+
+func fromTrackedAddresses(addresses []trackedAddress) tracking {
+	var tracked tracking
+	for _, address := range addresses {
+		tracked.names = append(tracked.names, address.Name)
+		tracked.addresses = append(tracked.addresses, address.Address)
+	}
+	return tracked
+}
+
+// applySettings replaces the model's windows, tracking lists and profiles with
+// the user's saved ones.
+func (m *model) applySettings(settings userSettings) {
+	if settings.Profiles != nil {
+		m.profiles = settings.Profiles
+	}
+	for chainId, chainSettings := range settings.Chains {
+		if chainSettings.Memory > 0 {
+			memory := m.memory[chainId]
+			memory.window = chainSettings.Memory
+			m.memory[chainId] = memory
+		}
+		m.trackingEOA[chainId] = fromTrackedAddresses(chainSettings.EOA)
+		m.trackingERC20[chainId] = fromTrackedAddresses(chainSettings.ERC20)
+		m.trackingERC721[chainId] = fromTrackedAddresses(chainSettings.ERC721)
+		m.trackingContracts[chainId] = fromTrackedAddresses(chainSettings.Contracts)
+	}
+}
+
+// saveSettings stores the current chain's set up and the user's profiles,
+// keeping what they saved for other chains.
+func (m *model) saveSettings() {
+	if m.user == "" {
+		return
+	}
+	current := chainSettings{
+		Memory:    m.memory[m.chain.Id].window,
+		EOA:       toTrackedAddresses(m.trackingEOA[m.chain.Id]),
+		ERC20:     toTrackedAddresses(m.trackingERC20[m.chain.Id]),
+		ERC721:    toTrackedAddresses(m.trackingERC721[m.chain.Id]),
+		Contracts: toTrackedAddresses(m.trackingContracts[m.chain.Id]),
+	}
+	err := m.app.settings.update(m.user, func(settings *userSettings) {
+		if settings.Chains == nil {
+			settings.Chains = make(map[string]chainSettings)
+		}
+		settings.Chains[m.chain.Id] = current
+		settings.Profiles = m.profiles
+	})
+	if err != nil {
+		log.Println("error saving settings", err)
+	}
+}
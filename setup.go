@@ -18,20 +18,28 @@ const (
 	EOAList
 	EOAName
 	EOAAddress
-	ERC721Name
-	ERC721Address
+	ERC20List
 	ERC20Name
 	ERC20Address
+	ERC721List
+	ERC721Name
+	ERC721Address
+	ContractList
+	ContractName
+	ContractAddress
 )
 
 type trackerInput struct {
 	list          list.Model
 	address, name textinput.Model
+	title         string
+	newName       string // entered name waiting for its address
 }
 
 type SetUpPage struct {
 	memory             textinput.Model
 	EOA, ERC721, ERC20 trackerInput
+	Contracts          trackerInput
 	container          viewport.Model
 	focus              int
 	help               string
@@ -39,11 +47,7 @@ type SetUpPage struct {
 }
 
 type newValues struct {
-	memory       int
-	EOAAddresses common.Address
-	EOANames     string
-	// ERC20
-	// etc
+	memory int
 }
 
 func (m *model) initializeSetUpPage() {
@@ -58,19 +62,21 @@ func (m *model) initializeSetUpPage() {
 	// }
 	m.setUpPage.memory.Focus()
 	// m.setUpPage.memory.Update()
-	m.setUpPage.EOA = m.newTrackerInput("EOA address")
-	// m.setUpPage.ERC20 = m.newTrackerInput("erc20 address")
-	// m.setUpPage.ERC721 = m.newTrackerInput("erc721 address")
+	m.setUpPage.EOA = m.newTrackerInput("EOA addresses being tracked", "EOA address", m.trackingEOA[m.chain.Id])
+	m.setUpPage.ERC20 = m.newTrackerInput("ERC20 tokens being tracked", "erc20 address", m.trackingERC20[m.chain.Id])
+	m.setUpPage.ERC721 = m.newTrackerInput("ERC721 collections being tracked", "erc721 address", m.trackingERC721[m.chain.Id])
+	m.setUpPage.Contracts = m.newTrackerInput("contracts whose events are decoded", "contract address", m.trackingContracts[m.chain.Id])
 	m.setUpPage.container = viewport.New(m.width/2, m.height-4)
 	m.setUpPage.container.Style = m.renderer.NewStyle().Border(lipgloss.NormalBorder())
 
 }
 
-func (m model) newTrackerInput(placeholder string) trackerInput {
+func (m model) newTrackerInput(title, placeholder string, tracked tracking) trackerInput {
 	input := trackerInput{
-		list:    m.initializeEOAList(),
+		list:    m.initializeTrackerList(title, tracked),
 		address: textinput.New(),
 		name:    textinput.New(),
+		title:   title,
 	}
 
 	input.address.Placeholder = placeholder
@@ -100,26 +106,26 @@ func (m *model) renderSetUp() string {
 		m.setUpPage.memory.View(),
 		"\n\n\n",
 		m.setUpPage.EOA.renderTrackerInput(),
-		// m.setUpPage.ERC721.renderTrackerInput("ERC721 addresses to track"),
-		// m.setUpPage.ERC20.renderTrackerInput("ERC20 addresses to track"),
+		m.setUpPage.ERC20.renderTrackerInput(),
+		m.setUpPage.ERC721.renderTrackerInput(),
+		m.setUpPage.Contracts.renderTrackerInput(),
 	))
 
 	return lipgloss.JoinVertical(lipgloss.Center, title, m.setUpPage.container.View())
 }
 
-func (setUp *SetUpPage) updateEOA() ([]string, []common.Address) {
+// tracked returns the names and addresses in the tracker's list.
+func (t trackerInput) tracked() ([]string, []common.Address) {
 
-	names := make([]string, len(setUp.EOA.list.Items()))
-	addresses := make([]common.Address, len(setUp.EOA.list.Items()))
+	names := make([]string, len(t.list.Items()))
+	addresses := make([]common.Address, len(t.list.Items()))
 
-	for i, value := range setUp.EOA.list.Items() {
+	for i, value := range t.list.Items() {
 		item, ok := value.(item)
 		if ok {
 			names[i] = item.name
 			addresses[i] = common.HexToAddress(item.description)
 		}
-
-		// names[index] = value.
 	}
 	return names, addresses
 
@@ -136,7 +142,6 @@ func (setUp *SetUpPage) update(msg tea.Msg) tea.Cmd {
 
 	switch setUp.focus {
 	case MemoryBlocks:
-		setUp.EOA.list.Title = "EOA addresses being tracked"
 		setUp.help = "'ctrl+z back' 'enter' next"
 		setUp.memory, cmd = setUp.memory.Update(msg)
 		cmds = append(cmds, cmd)
@@ -160,74 +165,90 @@ func (setUp *SetUpPage) update(msg tea.Msg) tea.Cmd {
 			}
 		}
 
-	case EOAList:
+	case EOAList, EOAName, EOAAddress:
+		cmds = append(cmds, setUp.updateTracker(&setUp.EOA, setUp.focus-EOAList, msg))
+
+	case ERC20List, ERC20Name, ERC20Address:
+		cmds = append(cmds, setUp.updateTracker(&setUp.ERC20, setUp.focus-ERC20List, msg))
+
+	case ERC721List, ERC721Name, ERC721Address:
+		cmds = append(cmds, setUp.updateTracker(&setUp.ERC721, setUp.focus-ERC721List, msg))
+
+	case ContractList, ContractName, ContractAddress:
+		cmds = append(cmds, setUp.updateTracker(&setUp.Contracts, setUp.focus-ContractList, msg))
+
+	}
+	return tea.Batch(cmds...)
+}
+
+// updateTracker handles input for one of the tracker's fields, step is 0 for
+// the list, 1 for the name and 2 for the address. Enter moves focus on to the
+// next field.
+func (setUp *SetUpPage) updateTracker(t *trackerInput, step int, msg tea.Msg) tea.Cmd {
+	var (
+		cmd  tea.Cmd
+		cmds []tea.Cmd
+	)
+
+	switch step {
+	case 0:
 		setUp.help = "'ctrl+z' back    'backspace' delete    '↑↓' select     'enter' next"
-		setUp.EOA.list.Title = "EOA addresses being tracked"
-		setUp.EOA.list, cmd = setUp.EOA.list.Update(msg)
+		t.list.Title = t.title
+		t.list, cmd = t.list.Update(msg)
 		cmds = append(cmds, cmd)
 
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "enter":
-				setUp.EOA.name.Focus()
-
-				// setUp.EOA.name, cmd = setUp.EOA.name.Update(msg)
+				t.name.Focus()
 				cmds = append(cmds, textinput.Blink)
 				setUp.focus++
-
-				// update the lists here!
 			case "backspace":
-				setUp.EOA.list.RemoveItem(setUp.EOA.list.Index())
-				// addressess := make([]common.Address, len(setUp.EOA.list.Items()))
-				// setUp.newValues.
-				// for i, item := range setUp.EOA.list.Items() {
-				// 	setUp.newValues.Addresses
-
-				// }
+				if len(t.list.Items()) > 0 {
+					t.list.RemoveItem(t.list.Index())
+				}
 			}
 		}
 
-	case EOAName:
+	case 1:
 		setUp.help = "'ctrl+z back' 'enter' next"
-		setUp.EOA.name, cmd = setUp.EOA.name.Update(msg)
+		t.name, cmd = t.name.Update(msg)
 		cmds = append(cmds, cmd)
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "enter":
-				setUp.EOA.name.Blur()
-				setUp.EOA.address.Focus()
+				t.name.Blur()
+				t.address.Focus()
 				cmds = append(cmds, textinput.Blink)
 				setUp.focus++
-				setUp.newValues.EOANames = setUp.EOA.name.Value()
+				t.newName = t.name.Value()
 
 			}
 
 		}
-	case EOAAddress:
+
+	case 2:
 		setUp.help = "'ctrl+z back' 'enter' next"
-		setUp.EOA.address, cmd = setUp.EOA.address.Update(msg)
+		t.address, cmd = t.address.Update(msg)
 		cmds = append(cmds, cmd)
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "enter":
-				setUp.EOA.address.Blur()
-				// setUp.ERC20.name
+				t.address.Blur()
 				setUp.focus++
 
-				if common.IsHexAddress(setUp.EOA.address.Value()) {
-					setUp.newValues.EOAAddresses = common.HexToAddress(setUp.EOA.address.Value())
-
+				if common.IsHexAddress(t.address.Value()) {
 					newItem := item{
-						name:        setUp.newValues.EOANames,
-						description: setUp.newValues.EOAAddresses.String(),
+						name:        t.newName,
+						description: common.HexToAddress(t.address.Value()).String(),
 					}
-					setUp.EOA.list.InsertItem(len(setUp.EOA.list.Items()), newItem)
+					t.list.InsertItem(len(t.list.Items()), newItem)
 
 				} else {
-					setUp.EOA.address.Reset()
+					t.address.Reset()
 				}
 
 			}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/big"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	defaultBlockTime = 2 * time.Second
+	fakeGenesis      = 1_000_000
+	fakeAccounts     = 12
+	fakeTokens       = 3
+	fakeCollections  = 2
+	maxFakeTxs       = 40
+	fakeGasLimit     = 30_000_000
+)
+
+var (
+	// selector of transfer(address,uint256)
+	transferSelector = crypto.Keccak256([]byte("transfer(address,uint256)"))[:4]
+	// decimals of the fake tokens, in turn
+	fakeTokenDecimals = []int64{18, 6, 8}
+)
+
+// fakeSource is an in-process chain for developing and testing offline. Block
+// contents only depend on the chain id and the block number so every session
+// sees the same chain, a new block is produced every blockTime.
+type fakeSource struct {
+	chain     chain
+	endpoint  *endpoint
+	index     int
+	total     int
+	seed      int64
+	start     time.Time
+	blockTime time.Duration
+	accounts  []common.Address
+	tokens    []common.Address
+	nfts      []common.Address
+}
+
+// newFakeSource makes the chain's source for the endpoint at index, its first
+// block was made at start.
+func newFakeSource(chain chain, index int, endpoints []*endpoint, start time.Time) *fakeSource {
+	h := fnv.New64a()
+	h.Write([]byte(chain.Id))
+
+	f := &fakeSource{
+		chain:     chain,
+		endpoint:  endpoints[index],
+		index:     index,
+		total:     len(endpoints),
+		seed:      int64(h.Sum64()),
+		start:     start,
+		blockTime: chain.blockTime(),
+	}
+	for i := range fakeAccounts {
+		f.accounts = append(f.accounts, f.address("account", i))
+	}
+	for i := range fakeTokens {
+		f.tokens = append(f.tokens, f.address("token", i))
+	}
+	for i := range fakeCollections {
+		f.nfts = append(f.nfts, f.address("collection", i))
+	}
+	log.Printf("%s tokens: %v, collections: %v", chain.Name, f.tokens, f.nfts)
+	return f
+}
+
+func (f *fakeSource) address(kind string, i int) common.Address {
+	return common.BytesToAddress(crypto.Keccak256([]byte(fmt.Sprint(f.seed, kind, i)))[12:])
+}
+
+func (f *fakeSource) blockHash(number uint64) common.Hash {
+	return crypto.Keccak256Hash([]byte(fmt.Sprint(f.seed, "block", number)))
+}
+
+func (f *fakeSource) chainId() *hexutil.Big {
+	id, _ := new(big.Int).SetString(f.chain.Id, 10)
+	return (*hexutil.Big)(id)
+}
+
+func (f *fakeSource) head() uint64 {
+	return fakeGenesis + uint64(time.Since(f.start)/f.blockTime)
+}
+
+func (f *fakeSource) Heads(ctx context.Context, headers chan<- *types.Header) (ethereum.Subscription, error) {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		ticker := time.NewTicker(f.blockTime)
+		defer ticker.Stop()
+
+		last := f.head()
+		for {
+			select {
+			case <-ticker.C:
+			case <-quit:
+				return nil
+			}
+			for ; last < f.head(); last++ {
+				select {
+				case headers <- &types.Header{Number: new(big.Int).SetUint64(last + 1)}:
+				case <-quit:
+					return nil
+				}
+			}
+		}
+	}), nil
+}
+
+func (f *fakeSource) Call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	start := time.Now()
+	response, err := f.respond(method, args)
+	if err == nil {
+		var raw []byte
+		raw, err = json.Marshal(response)
+		if err == nil {
+			err = json.Unmarshal(raw, result)
+		}
+	}
+	f.endpoint.observe(time.Since(start), err)
+	return err
+}
+
+func (f *fakeSource) BatchCall(ctx context.Context, calls []rpc.BatchElem) error {
+	return batchEach(ctx, f, calls)
+}
+
+// respond answers the JSON-RPC methods the app uses.
+func (f *fakeSource) respond(method string, args []interface{}) (interface{}, error) {
+	switch method {
+	case "eth_chainId":
+		return f.chainId(), nil
+
+	case "eth_blockNumber":
+		return hexutil.Uint64(f.head()), nil
+
+	case "eth_getBlockByNumber":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("missing value for required argument")
+		}
+		number, ok := f.blockNumberArg(args[0])
+		if !ok {
+			return nil, nil
+		}
+		full, _ := args[1].(bool)
+		return f.block(number, full), nil
+
+	case "eth_getLogs":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("missing value for required argument")
+		}
+		return f.logs(args[0])
+
+	case "eth_getBlockReceipts":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("missing value for required argument")
+		}
+		number, ok := f.blockNumberArg(args[0])
+		if !ok {
+			return nil, nil
+		}
+		txs := f.transactions(number)
+		receipts := make([]map[string]interface{}, len(txs))
+		for i, tx := range txs {
+			receipts[i] = tx.receipt()
+		}
+		return receipts, nil
+
+	case "eth_call":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("missing value for required argument")
+		}
+		blockArg := interface{}("latest")
+		if len(args) > 1 {
+			blockArg = args[1]
+		}
+		return f.call(args[0], blockArg)
+
+	case "eth_getBalance":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("missing value for required argument")
+		}
+		address, _ := args[0].(common.Address)
+		number, ok := f.blockNumberArg(args[1])
+		if !ok {
+			return nil, fmt.Errorf("header not found")
+		}
+		return (*hexutil.Big)(f.balance(address, number)), nil
+
+	case "eth_getTransactionReceipt":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("missing value for required argument")
+		}
+		hash, _ := args[0].(common.Hash)
+		for number := f.head(); number >= fakeGenesis && number+maxReorgDepth > f.head(); number-- {
+			for _, tx := range f.transactions(number) {
+				if tx.tx["hash"] == hash {
+					return tx.receipt(), nil
+				}
+			}
+		}
+		return nil, nil
+	}
+
+	return nil, methodNotFoundError{method}
+}
+
+// blockNumberArg resolves a block tag or hex number, ok is false for blocks
+// that don't exist yet.
+func (f *fakeSource) blockNumberArg(arg interface{}) (uint64, bool) {
+	head := f.head()
+	tag, _ := arg.(string)
+	switch tag {
+	case "", "latest", "safe", "finalized", "pending":
+		return head, true
+	case "earliest":
+		return fakeGenesis, true
+	}
+	number, err := hexutil.DecodeUint64(tag)
+	if err != nil || number > head || number < fakeGenesis {
+		return 0, false
+	}
+	return number, true
+}
+
+type fakeTx struct {
+	tx     map[string]interface{}
+	logs   []map[string]interface{}
+	failed bool
+}
+
+// transactions generates the block's transactions, a share of them are token
+// or nft transfers that also emit a Transfer log.
+func (f *fakeSource) transactions(number uint64) []fakeTx {
+	r := rand.New(rand.NewSource(f.seed + int64(number)))
+	blockHash := f.blockHash(number)
+	baseFee := f.baseFee(number)
+
+	txs := make([]fakeTx, r.Intn(maxFakeTxs))
+	for i := range txs {
+		hash := crypto.Keccak256Hash([]byte(fmt.Sprint(f.seed, "tx", number, i)))
+		from := f.accounts[r.Intn(len(f.accounts))]
+		to := f.accounts[r.Intn(len(f.accounts))]
+		value := new(big.Int).Mul(big.NewInt(r.Int63n(5_000)), big.NewInt(1e15))
+		gas := uint64(21_000)
+		input := []byte{}
+		tip := big.NewInt(r.Int63n(2e9))
+
+		var logs []map[string]interface{}
+		if r.Intn(3) == 0 {
+			index := r.Intn(len(f.tokens))
+			token := f.tokens[index]
+			unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(fakeTokenDecimals[index%len(fakeTokenDecimals)]), nil)
+			amount := new(big.Int).Mul(big.NewInt(r.Int63n(10_000)), unit)
+			input = append(append(append(input, transferSelector...), common.LeftPadBytes(to.Bytes(), 32)...), common.LeftPadBytes(amount.Bytes(), 32)...)
+			logs = append(logs, map[string]interface{}{
+				"address":          token,
+				"topics":           []common.Hash{transferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+				"data":             hexutil.Bytes(common.LeftPadBytes(amount.Bytes(), 32)),
+				"blockNumber":      hexutil.Uint64(number),
+				"blockHash":        blockHash,
+				"transactionHash":  hash,
+				"transactionIndex": hexutil.Uint(i),
+				"logIndex":         hexutil.Uint(i),
+				"removed":          false,
+			})
+			to = token
+			value = new(big.Int)
+			gas = 65_000
+		} else if r.Intn(6) == 0 {
+			// an nft changing hands, or minted to the sender one time in four
+			collection := f.nfts[r.Intn(len(f.nfts))]
+			tokenId := big.NewInt(r.Int63n(10_000))
+			owner := from
+			if r.Intn(4) == 0 {
+				owner = common.Address{}
+			}
+			logs = append(logs, map[string]interface{}{
+				"address":          collection,
+				"topics":           []common.Hash{transferTopic, common.BytesToHash(owner.Bytes()), common.BytesToHash(to.Bytes()), common.BigToHash(tokenId)},
+				"data":             hexutil.Bytes{},
+				"blockNumber":      hexutil.Uint64(number),
+				"blockHash":        blockHash,
+				"transactionHash":  hash,
+				"transactionIndex": hexutil.Uint(i),
+				"logIndex":         hexutil.Uint(i),
+				"removed":          false,
+			})
+			to = collection
+			value = new(big.Int)
+			gas = 90_000
+		}
+
+		// one in twenty reverts, taking its logs with it
+		failed := r.Intn(20) == 0
+		if failed {
+			logs = nil
+		}
+
+		txs[i] = fakeTx{
+			tx: map[string]interface{}{
+				"hash":                 hash,
+				"blockHash":            blockHash,
+				"blockNumber":          hexutil.Uint64(number),
+				"transactionIndex":     hexutil.Uint(i),
+				"from":                 from,
+				"to":                   to,
+				"value":                (*hexutil.Big)(value),
+				"gas":                  hexutil.Uint64(gas),
+				"gasPrice":             (*hexutil.Big)(new(big.Int).Add(baseFee, tip)),
+				"maxFeePerGas":         (*hexutil.Big)(new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), tip)),
+				"maxPriorityFeePerGas": (*hexutil.Big)(tip),
+				"nonce":                hexutil.Uint64(r.Intn(1_000)),
+				"input":                hexutil.Bytes(input),
+				"type":                 hexutil.Uint64(types.DynamicFeeTxType),
+				"chainId":              f.chainId(),
+			},
+			logs:   logs,
+			failed: failed,
+		}
+	}
+	return txs
+}
+
+func (tx fakeTx) receipt() map[string]interface{} {
+	status := hexutil.Uint64(1)
+	if tx.failed {
+		status = 0
+	}
+	logs := tx.logs
+	if logs == nil {
+		logs = []map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"transactionHash":   tx.tx["hash"],
+		"transactionIndex":  tx.tx["transactionIndex"],
+		"blockHash":         tx.tx["blockHash"],
+		"blockNumber":       tx.tx["blockNumber"],
+		"from":              tx.tx["from"],
+		"to":                tx.tx["to"],
+		"type":              tx.tx["type"],
+		"status":            status,
+		"gasUsed":           tx.tx["gas"],
+		"effectiveGasPrice": tx.tx["gasPrice"],
+		"logs":              logs,
+	}
+}
+
+// balance starts an account somewhere under 100 eth and drifts it up or down
+// a little every block, it doesn't follow the generated transactions.
+func (f *fakeSource) balance(address common.Address, number uint64) *big.Int {
+	h := fnv.New64a()
+	h.Write(address.Bytes())
+	r := rand.New(rand.NewSource(f.seed + int64(h.Sum64())))
+	start := new(big.Int).Mul(big.NewInt(r.Int63n(100_000)), big.NewInt(1e15))
+	drift := new(big.Int).Mul(big.NewInt(r.Int63n(2_000)-1_000), big.NewInt(1e12))
+	balance := new(big.Int).Add(start, drift.Mul(drift, new(big.Int).SetUint64(number-fakeGenesis)))
+	if balance.Sign() < 0 {
+		return new(big.Int)
+	}
+	return balance
+}
+
+// baseFee wanders between 1 and 50 gwei.
+func (f *fakeSource) baseFee(number uint64) *big.Int {
+	r := rand.New(rand.NewSource(f.seed - int64(number)))
+	return big.NewInt(1e9 + r.Int63n(49e9))
+}
+
+func (f *fakeSource) block(number uint64, full bool) map[string]interface{} {
+	txs := f.transactions(number)
+
+	var gasUsed uint64
+	transactions := make([]interface{}, len(txs))
+	for i, tx := range txs {
+		gasUsed += uint64(tx.tx["gas"].(hexutil.Uint64))
+		if full {
+			transactions[i] = tx.tx
+		} else {
+			transactions[i] = tx.tx["hash"]
+		}
+	}
+
+	timestamp := f.start.Add(time.Duration(number-fakeGenesis) * f.blockTime).Unix()
+	return map[string]interface{}{
+		"number":        hexutil.Uint64(number),
+		"hash":          f.blockHash(number),
+		"parentHash":    f.blockHash(number - 1),
+		"timestamp":     hexutil.Uint64(timestamp),
+		"miner":         f.address("miner", int(number%4)),
+		"gasLimit":      hexutil.Uint64(fakeGasLimit),
+		"gasUsed":       hexutil.Uint64(gasUsed),
+		"baseFeePerGas": (*hexutil.Big)(f.baseFee(number)),
+		"size":          hexutil.Uint64(600 + 110*len(txs)),
+		"transactions":  transactions,
+	}
+}
+
+type fakeCall struct {
+	To    *common.Address `json:"to"`
+	Data  hexutil.Bytes   `json:"data"`
+	Input hexutil.Bytes   `json:"input"`
+}
+
+// call answers decimals(), symbol() and balanceOf() for the fake tokens, also
+// through Multicall3's aggregate3 at the chain's multicall address. Anything
+// else returns nothing, like a contract without the method.
+func (f *fakeSource) call(arg interface{}, blockArg interface{}) (hexutil.Bytes, error) {
+	raw, err := json.Marshal(arg)
+	if err != nil {
+		return nil, err
+	}
+	var call fakeCall
+	if err := json.Unmarshal(raw, &call); err != nil {
+		return nil, err
+	}
+	data := call.Input
+	if len(data) == 0 {
+		data = call.Data
+	}
+	number, ok := f.blockNumberArg(blockArg)
+	if !ok {
+		return nil, fmt.Errorf("header not found")
+	}
+
+	if call.To == nil || *call.To != f.chain.multicall() {
+		return f.answer(call.To, data, number), nil
+	}
+
+	method, err := multicallABI.MethodById(data)
+	if err != nil || method.Name != "aggregate3" {
+		return hexutil.Bytes{}, nil
+	}
+	values, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, err
+	}
+	calls := *abi.ConvertType(values[0], new([]multicall)).(*[]multicall)
+	results := make([]multicallResult, len(calls))
+	for i, inner := range calls {
+		target := inner.Target
+		returned := f.answer(&target, inner.CallData, number)
+		results[i] = multicallResult{Success: len(returned) > 0, ReturnData: returned}
+	}
+	return method.Outputs.Pack(results)
+}
+
+func (f *fakeSource) answer(to *common.Address, data []byte, number uint64) hexutil.Bytes {
+	token := -1
+	if to != nil {
+		token = slices.Index(f.tokens, *to)
+	}
+	if token < 0 {
+		return hexutil.Bytes{}
+	}
+	switch {
+	case bytes.Equal(data, decimalsSelector):
+		decimals := fakeTokenDecimals[token%len(fakeTokenDecimals)]
+		return common.LeftPadBytes(big.NewInt(decimals).Bytes(), 32)
+	case bytes.Equal(data, symbolSelector):
+		stringType, _ := abi.NewType("string", "", nil)
+		symbol, _ := abi.Arguments{{Type: stringType}}.Pack(fmt.Sprint("FAKE", token))
+		return symbol
+	case len(data) == 36 && bytes.Equal(data[:4], balanceOfSelector):
+		holder := common.BytesToAddress(data[4:])
+		unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(fakeTokenDecimals[token%len(fakeTokenDecimals)]), nil)
+		// the native balance in thousandths, scaled differently per token
+		whole := new(big.Int).Div(f.balance(holder, number), big.NewInt(1e15))
+		whole.Mul(whole, big.NewInt(int64(token+1)))
+		return common.LeftPadBytes(whole.Mul(whole, unit).Bytes(), 32)
+	}
+	return hexutil.Bytes{}
+}
+
+type fakeLogFilter struct {
+	BlockHash *common.Hash    `json:"blockHash"`
+	FromBlock string          `json:"fromBlock"`
+	ToBlock   string          `json:"toBlock"`
+	Address   json.RawMessage `json:"address"`
+	Topics    []interface{}   `json:"topics"`
+}
+
+// logs answers eth_getLogs for a block range or hash, filtering on address and
+// on the first topic.
+func (f *fakeSource) logs(arg interface{}) ([]map[string]interface{}, error) {
+	raw, err := json.Marshal(arg)
+	if err != nil {
+		return nil, err
+	}
+	var filter fakeLogFilter
+	if err := json.Unmarshal(raw, &filter); err != nil {
+		return nil, err
+	}
+
+	from, ok := f.blockNumberArg(filter.FromBlock)
+	if !ok {
+		return []map[string]interface{}{}, nil
+	}
+	to, ok := f.blockNumberArg(filter.ToBlock)
+	if !ok {
+		to = f.head()
+	}
+	if filter.BlockHash != nil {
+		from, to = 0, 0
+		for number := f.head(); number >= fakeGenesis && number+maxReorgDepth > f.head(); number-- {
+			if f.blockHash(number) == *filter.BlockHash {
+				from, to = number, number
+				break
+			}
+		}
+	}
+
+	var addresses []common.Address
+	if len(filter.Address) > 0 && string(filter.Address) != "null" {
+		if err := json.Unmarshal(filter.Address, &addresses); err != nil {
+			var address common.Address
+			if err := json.Unmarshal(filter.Address, &address); err != nil {
+				return nil, err
+			}
+			addresses = []common.Address{address}
+		}
+	}
+
+	var topic0 []common.Hash
+	if len(filter.Topics) > 0 && filter.Topics[0] != nil {
+		raw, _ := json.Marshal(filter.Topics[0])
+		if err := json.Unmarshal(raw, &topic0); err != nil {
+			var topic common.Hash
+			if err := json.Unmarshal(raw, &topic); err != nil {
+				return nil, err
+			}
+			topic0 = []common.Hash{topic}
+		}
+	}
+
+	logs := []map[string]interface{}{}
+	for number := from; number <= to && from != 0; number++ {
+		for _, tx := range f.transactions(number) {
+			for _, l := range tx.logs {
+				if len(addresses) > 0 && !slices.Contains(addresses, l["address"].(common.Address)) {
+					continue
+				}
+				if len(topic0) > 0 && !slices.Contains(topic0, l["topics"].([]common.Hash)[0]) {
+					continue
+				}
+				logs = append(logs, l)
+			}
+		}
+	}
+	return logs, nil
+}
+
+func (f *fakeSource) Status() EndpointMsg {
+	status := f.endpoint.status()
+	status.index, status.total = f.index, f.total
+	return status
+}
+
+func (f *fakeSource) Close() {}
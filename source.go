@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// BlockSource is everything a chain listener reads from. Calls take the usual
+// JSON-RPC method names and arguments so a source that isn't a node only has to
+// answer the handful of methods the listener uses.
+type BlockSource interface {
+	// Heads sends a header (only Number is guaranteed) for every new block
+	// until the returned subscription ends.
+	Heads(ctx context.Context, headers chan<- *types.Header) (ethereum.Subscription, error)
+	Call(ctx context.Context, result interface{}, method string, args ...interface{}) error
+	// BatchCall sends the calls as a single JSON-RPC batch where the source
+	// supports it, per call errors are set on the elements.
+	BatchCall(ctx context.Context, calls []rpc.BatchElem) error
+	Status() EndpointMsg
+	Close()
+}
+
+// dialSource opens the source for the chain's endpoint at index.
+func dialSource(ctx context.Context, info *chainInfo, index int) (BlockSource, error) {
+	chain, endpoints := info.chain, info.endpoints
+	switch chain.Source {
+	case "simulated":
+		return newFakeSource(chain, index, endpoints, info.genesis), nil
+	case "replay":
+		if info.replay == nil {
+			replay, err := newReplaySource(chain, endpoints[index])
+			if err != nil {
+				return nil, err
+			}
+			info.replay = replay
+		}
+		return info.replay, nil
+	}
+
+	endpoint := endpoints[index]
+	client, err := ethclient.DialContext(ctx, endpoint.url)
+	if err != nil {
+		return nil, fmt.Errorf("error creating eth client: %w", err)
+	}
+	return &conn{
+		client:   client,
+		chain:    chain,
+		endpoint: endpoint,
+		index:    index,
+		total:    len(endpoints),
+	}, nil
+}
+
+// conn is the BlockSource for a node's JSON-RPC endpoint, every call through it
+// feeds the endpoint's score.
+type conn struct {
+	client       *ethclient.Client
+	chain        chain
+	endpoint     *endpoint
+	index        int
+	total        int
+	pollInterval time.Duration // zero while heads come from a subscription
+}
+
+func (c *conn) Call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	if err := c.endpoint.limiter.wait(ctx, 1); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := c.client.Client().CallContext(ctx, result, method, args...)
+	c.endpoint.observe(time.Since(start), err)
+	return err
+}
+
+func (c *conn) BatchCall(ctx context.Context, calls []rpc.BatchElem) error {
+	if err := c.endpoint.limiter.wait(ctx, len(calls)); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := c.client.Client().BatchCallContext(ctx, calls)
+	c.endpoint.observe(time.Since(start), err)
+	return err
+}
+
+// Heads subscribes to new heads, or polls for them when the chain is configured
+// to or the endpoint can't do subscriptions.
+func (c *conn) Heads(ctx context.Context, headers chan<- *types.Header) (ethereum.Subscription, error) {
+	if c.chain.polls(c.endpoint.url) {
+		return c.pollHeads(c.chain.pollInterval(), headers), nil
+	}
+
+	sub, err := c.client.SubscribeNewHead(ctx, headers)
+	if err != nil {
+		log.Printf("%s subscription failed, falling back to polling: %v", c.chain.Name, err)
+		return c.pollHeads(c.chain.pollInterval(), headers), nil
+	}
+	return sub, nil
+}
+
+func (c *conn) Status() EndpointMsg {
+	status := c.endpoint.status()
+	status.index, status.total = c.index, c.total
+	status.pollInterval = c.pollInterval
+	return status
+}
+
+func (c *conn) Close() {
+	c.client.Close()
+}
+
+// batchEach answers a batch one call at a time, for sources without a wire to
+// batch over.
+func batchEach(ctx context.Context, source BlockSource, calls []rpc.BatchElem) error {
+	for i := range calls {
+		calls[i].Error = source.Call(ctx, calls[i].Result, calls[i].Method, calls[i].Args...)
+	}
+	return nil
+}
+
+// pollHeads asks the endpoint for its latest block number every interval and
+// sends a header for each new one, for endpoints that can't push new heads. It
+// gives up after maxPollFailures calls in a row fail so the listener can fail
+// over.
+func (c *conn) pollHeads(interval time.Duration, headers chan<- *types.Header) ethereum.Subscription {
+	c.pollInterval = interval
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-quit
+			cancel()
+		}()
+
+		var last uint64
+		failures := 0
+		for {
+			var number hexutil.Uint64
+			err := c.Call(ctx, &number, "eth_blockNumber")
+			switch {
+			case ctx.Err() != nil:
+				return nil
+			case err != nil:
+				failures++
+				if failures >= maxPollFailures {
+					return fmt.Errorf("polling failed %d times: %w", failures, err)
+				}
+			case uint64(number) > last:
+				failures = 0
+				last = uint64(number)
+				select {
+				case headers <- &types.Header{Number: new(big.Int).SetUint64(last)}:
+				case <-quit:
+					return nil
+				}
+			default:
+				failures = 0
+			}
+
+			select {
+			case <-ticker.C:
+			case <-quit:
+				return nil
+			}
+		}
+	})
+}
@@ -37,3 +37,11 @@ func filter[T any](ss []T, test func(T) bool) (ret []T) {
 	return
 }
 
+// truncate cuts s to at most n characters, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}